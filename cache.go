@@ -0,0 +1,76 @@
+package edgar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diskCache stores the raw pages fetched from EDGAR in a directory keyed
+// by the URL of the page. Pages of archived filings never change once
+// filed and are cached forever. Other pages, like the company queries,
+// change with every new filing and are cached only for the ttl.
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// immutablePage returns true for pages that belong to a filing.
+// These pages are the filing viewer and everything under the archives.
+func immutablePage(url string) bool {
+	return strings.Contains(url, "/Archives/") ||
+		strings.Contains(url, "cgi-bin/viewer")
+}
+
+func (d *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// get returns the cached page for the URL if it is available and fresh
+func (d *diskCache) get(url string) ([]byte, bool) {
+	if d == nil {
+		return nil, false
+	}
+	name := d.path(url)
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, false
+	}
+	if !immutablePage(url) && time.Since(info.ModTime()) > d.ttl {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// put stores the page in the cache. The page is written to a temporary
+// file first so that parallel fetches never see a partial page.
+func (d *diskCache) put(url string, b []byte) error {
+	if d == nil || (!immutablePage(url) && d.ttl <= 0) {
+		return nil
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(d.dir, "page")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), d.path(url))
+}
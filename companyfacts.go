@@ -0,0 +1,206 @@
+package edgar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+)
+
+// The filing types collected from a companyfacts document when no filing
+// type is asked for
+var companyFactsTypes = []FilingType{
+	FilingType10Q, FilingType10K, FilingType20F, FilingType40F,
+}
+
+// companyFact is a fact of a company as reported in one of its filings
+type companyFact struct {
+	Start string      `json:"start"`
+	End   string      `json:"end"`
+	Val   json.Number `json:"val"`
+	Accn  string      `json:"accn"`
+	FY    int         `json:"fy"`
+	FP    string      `json:"fp"`
+	Form  string      `json:"form"`
+	Filed string      `json:"filed"`
+}
+
+// companyConcept is a concept of a taxonomy with the facts reported for
+// the concept by unit
+type companyConcept struct {
+	Units map[string][]companyFact `json:"units"`
+}
+
+// companyFacts is the companyfacts document of a company. The facts are
+// listed by taxonomy, concept and unit.
+// Ex: facts["us-gaap"]["Assets"].Units["USD"]
+type companyFacts struct {
+	CIK        int                                  `json:"cik"`
+	EntityName string                               `json:"entityName"`
+	Facts      map[string]map[string]companyConcept `json:"facts"`
+}
+
+// factsFiling is a filing made out of the facts reported in the filing.
+// Every fact tells the fiscal year and period of the filing.
+type factsFiling struct {
+	link filingLink
+	inst *xbrlInstance
+	fy   int
+	fp   string
+}
+
+func companyFactsParser(page io.Reader) (*companyFacts, error) {
+	cf := new(companyFacts)
+	if err := json.NewDecoder(page).Decode(cf); err != nil {
+		return nil, err
+	}
+	if cf.CIK == 0 || len(cf.Facts) == 0 {
+		return nil, errors.New("No facts found in the companyfacts document")
+	}
+	return cf, nil
+}
+
+// filings groups the facts by the filings of the wanted types that
+// reported them. The filings are keyed by their accession number.
+func (cf *companyFacts) filings(fileTypes []FilingType, threshold int) map[string]*factsFiling {
+	wanted := make(map[FilingType]bool)
+	for _, t := range fileTypes {
+		wanted[t] = true
+	}
+	var prefixes []string
+	for prefix := range cf.Facts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	// The facts are added in a fixed order for the same facts to be picked
+	// for a filing every time
+	filings := make(map[string]*factsFiling)
+	for _, prefix := range prefixes {
+		var concepts []string
+		for concept := range cf.Facts[prefix] {
+			concepts = append(concepts, concept)
+		}
+		sort.Strings(concepts)
+		for _, concept := range concepts {
+			units := cf.Facts[prefix][concept].Units
+			var names []string
+			for unit := range units {
+				names = append(names, unit)
+			}
+			sort.Strings(names)
+			for _, unit := range names {
+				for _, fact := range units[unit] {
+					if !wanted[FilingType(fact.Form)] || getYear(fact.Filed) < threshold {
+						continue
+					}
+					f, ok := filings[fact.Accn]
+					if !ok {
+						f = &factsFiling{
+							link: filingLink{
+								Link:       fmt.Sprintf(viewerURL, cf.CIK, fact.Accn),
+								Accession:  fact.Accn,
+								Form:       fact.Form,
+								FilingDate: fact.Filed,
+								IsXBRL:     true,
+							},
+							inst: newXBRLInstance(),
+						}
+						filings[fact.Accn] = f
+					}
+					f.add(prefix, concept, unit, fact)
+				}
+			}
+		}
+	}
+	for _, f := range filings {
+		f.link.ReportDate = f.inst.periodEnd()
+	}
+	return filings
+}
+
+// add adds a fact to the instance of the filing. The contexts of the facts
+// are made out of the periods of the facts.
+func (f *factsFiling) add(prefix string, concept string, unit string, fact companyFact) {
+	if f.fy == 0 {
+		f.fy, f.fp = fact.FY, fact.FP
+	}
+	ctx := xbrlContext{ID: fact.Start + "_" + fact.End}
+	if fact.Start == "" {
+		ctx.Period.Instant = fact.End
+	} else {
+		ctx.Period.StartDate = fact.Start
+		ctx.Period.EndDate = fact.End
+	}
+	f.inst.Contexts[ctx.ID] = ctx
+	f.inst.Units[unit] = unit
+	f.inst.Facts = append(f.inst.Facts, xbrlFact{
+		Key:     "defref_" + prefix + "_" + concept,
+		Prefix:  prefix,
+		Context: ctx.ID,
+		Unit:    unit,
+		Value:   fact.Val.String(),
+	})
+}
+
+// newFactsCompany creates a company folder with the filings of the company
+// made out of its companyfacts document. The filings of the folder are
+// filled in and no filing is fetched from EDGAR.
+func newFactsCompany(c *client, ticker string, cf *companyFacts, fileTypes ...FilingType) *company {
+	if len(fileTypes) == 0 {
+		fileTypes = companyFactsTypes
+	}
+	comp := newCompany(c, ticker)
+	comp.CentralIndexKey = fmt.Sprintf("%010d", cf.CIK)
+
+	filings := cf.filings(withAmendments(fileTypes), c.threshold)
+	for _, f := range filings {
+		t := FilingType(f.link.Form)
+		if comp.FilingLinks[t] == nil {
+			comp.FilingLinks[t] = make(map[string]filingLink)
+		}
+		comp.FilingLinks[t][f.link.FilingDate] = f.link
+	}
+	var files []*filing
+	quarters := make(map[string]*filing)
+	for _, f := range filings {
+		t := FilingType(f.link.Form)
+		file := &filing{
+			Company: ticker,
+			Date:    getDate(f.link.FilingDate),
+			FinData: f.inst.financialReport(t, c.mappings.metricTags(ticker)),
+		}
+		setDocumentInfo(file.FinData.Entity, "defref_dei_DocumentPeriodEndDate", f.link.ReportDate)
+		setDocumentInfo(file.FinData.Entity, "defref_dei_DocumentFiscalYearFocus", strconv.Itoa(f.fy))
+		setDocumentInfo(file.FinData.Entity, "defref_dei_DocumentFiscalPeriodFocus", f.fp)
+		if original, ok := comp.original(t, f.link); ok {
+			file.Original = &original
+		}
+		if err := validateFinancialReport(file.FinData); err != nil {
+			log.Println(ticker + "-Filed on: " + f.link.FilingDate + ":" + err.Error())
+		}
+		files = append(files, file)
+		if t == FilingType10Q {
+			quarters[f.link.FilingDate] = file
+		}
+	}
+	// The quarters are derived out of the quarter filed before them before
+	// the filings are added to the folder
+	var filed []string
+	for date := range quarters {
+		filed = append(filed, date)
+	}
+	sort.Strings(filed)
+	for i := 1; i < len(filed); i++ {
+		if file := quarters[filed[i]]; file.FinData.quarterMissing() {
+			file.FinData.deriveQuarter(quarters[filed[i-1]].FinData)
+		}
+	}
+	for _, file := range files {
+		comp.AddReport(file)
+	}
+	return comp
+}
@@ -23,8 +23,8 @@ type scaleInfo struct {
 
 var (
 
-	// Threshold year is the earliest year for which we will collect data
-	thresholdYear = 2012
+	// Threshold year is the default earliest year for which we will collect data
+	defaultThresholdYear = 2012
 
 	//Document types
 	filingDocOps      filingDocType = "Operations"
@@ -244,5 +244,9 @@ func setData(fr *financialReport,
 	if err = setter(fr.Ops, finType, val, scale); err == nil {
 		return nil
 	}
+	// Custom metrics of the company are not fields of the report
+	if _, ok := fr.tags.customEntity(finType); ok {
+		return fr.setCustom(finType, val, scale)
+	}
 	return err
 }
@@ -1,6 +1,7 @@
 package edgar
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -14,11 +15,137 @@ const FilingType10Q FilingType = "10-Q"
 // FilingType10K is a 10-K annual filing of a company with the SEC
 const FilingType10K FilingType = "10-K"
 
+// FilingType20F is a 20-F annual filing of a foreign private issuer with the SEC
+const FilingType20F FilingType = "20-F"
+
+// FilingType40F is a 40-F annual filing of a Canadian issuer with the SEC
+const FilingType40F FilingType = "40-F"
+
+// FilingType10QA is an amendment to a 10-Q filing
+const FilingType10QA FilingType = "10-Q/A"
+
+// FilingType10KA is an amendment to a 10-K filing
+const FilingType10KA FilingType = "10-K/A"
+
+// FilingType20FA is an amendment to a 20-F filing
+const FilingType20FA FilingType = "20-F/A"
+
+// FilingType40FA is an amendment to a 40-F filing
+const FilingType40FA FilingType = "40-F/A"
+
+// Metric is a financial data item collected from the filings of companies.
+// Every metric has an accessor of the same name in the Filing interface.
+type Metric string
+
+// The metrics collected from the filings
+const (
+	MetricShareCount         Metric = "Shares Outstanding"
+	MetricRevenue            Metric = "Revenue"
+	MetricCostOfRevenue      Metric = "Cost Of Revenue"
+	MetricGrossMargin        Metric = "Gross Margin"
+	MetricOperatingIncome    Metric = "Operational Income"
+	MetricOperatingExpense   Metric = "Operational Expense"
+	MetricNetIncome          Metric = "Net Income"
+	MetricTotalEquity        Metric = "Total Shareholder Equity"
+	MetricShortTermDebt      Metric = "Short-Term debt"
+	MetricLongTermDebt       Metric = "Long-Term debt"
+	MetricCurrentLiabilities Metric = "Current Liabilities"
+	MetricCurrentAssets      Metric = "Current Assets"
+	MetricDeferredRevenue    Metric = "Deferred revenue"
+	MetricRetainedEarnings   Metric = "Retained Earnings"
+	MetricOperatingCashFlow  Metric = "Operating Cash Flow"
+	MetricCapitalExpenditure Metric = "Capital Expenditure"
+	MetricDividend           Metric = "Dividends paid"
+	MetricWAShares           Metric = "Weighted Average Share Count"
+	MetricDividendPerShare   Metric = "Dividend Per Share"
+	MetricInterest           Metric = "Interest paid"
+	MetricCash               Metric = "Cash"
+	MetricSecurities         Metric = "Securities"
+	MetricGoodwill           Metric = "Goodwill"
+	MetricIntangibles        Metric = "Intangibles"
+	MetricAssets             Metric = "Total Assets"
+	MetricLiabilities        Metric = "Total Liabilities"
+)
+
+// FrameValue is the value of a metric reported by a company for a
+// calendar period in the frames of EDGAR
+type FrameValue struct {
+	CIK    string
+	Ticker string
+	Name   string
+	Metric Metric
+	// Concept is the XBRL concept the value was reported with.
+	// Ex: us-gaap:Revenues
+	Concept   string
+	Unit      string
+	Accession string
+	// Start is the start of the period of the value. It is zero for values
+	// reported as of an instant, like the balance sheet
+	Start time.Time
+	End   time.Time
+	Value float64
+}
+
+// PeriodValue is the value of a metric for a period reported in a filing.
+// Statements report the values of prior periods next to the values of the
+// period of the filing.
+type PeriodValue struct {
+	Metric Metric
+	End    time.Time
+	// Months is the length of the period. It is 0 for values as of an
+	// instant, like the balance sheet, and when the statement does not
+	// report the length of the period
+	Months int
+	Value  float64
+}
+
+// Fact is the value of an XBRL concept reported in a filing, whether or not
+// the concept is one of the metrics collected
+type Fact struct {
+	// Concept is the XBRL concept of the fact. Ex: us-gaap:Revenues
+	Concept string
+	// Value is in units, like Unit. Ex: USD, shares or USD/shares
+	Value float64
+	Unit  string
+	// Scale is the factor that the value is presented in by the report.
+	// Ex: 1000000 for $ in Millions
+	Scale  float64
+	End    time.Time
+	Months int
+	// Report is the report of the filing that the fact is read from. It is
+	// empty for facts read out of the XBRL documents. Ex: Operations
+	Report string
+}
+
 // Filing interface for fetching financial data from a collected filing
 type Filing interface {
 	Ticker() string
 	FiledOn() time.Time
 	Type() (FilingType, error)
+
+	// IsAmendment tells if the filing amends an earlier filing
+	IsAmendment() bool
+
+	// Amends gets the date of filing of the original filing that
+	// this amendment amends
+	Amends() (time.Time, error)
+
+	// Amendments gets the dates of filing of the amendments merged into
+	// this filing. Amendments are merged only into the latest amended
+	// view of a filing. See WithAmendedFilings
+	Amendments() []time.Time
+
+	// PeriodEnd gets the end of the period reported in the filing, which
+	// is the date of its balance sheet
+	PeriodEnd() (time.Time, error)
+
+	// FiscalYear gets the fiscal year that the filing reports on
+	FiscalYear() (int, error)
+
+	// FiscalPeriod gets the fiscal period that the filing reports on.
+	// Ex: Q1, Q2, Q3 or FY
+	FiscalPeriod() (string, error)
+
 	ShareCount() (float64, error)
 	Revenue() (float64, error)
 	CostOfRevenue() (float64, error)
@@ -46,6 +173,30 @@ type Filing interface {
 	Assets() (float64, error)
 	Liabilities() (float64, error)
 	CollectedData() []string
+
+	// Value gets the value of a metric of the package or of a custom
+	// metric of the mappings of the fetcher. See WithMetricMappings
+	Value(metric Metric) (float64, error)
+
+	// PeriodValues gets the values of a metric for every period reported
+	// in the filing, the latest period first. Comparing the values of the
+	// same period in consecutive filings shows restatements.
+	PeriodValues(metric Metric) []PeriodValue
+
+	// IsDerived tells if the value of a metric is derived from the values
+	// reported in the filings instead of being reported in the filing. The
+	// quarter of a quarterly filing that reports only the year to date is
+	// derived from the year to date of the previous quarter.
+	IsDerived(metric Metric) bool
+
+	// Fact gets the fact of an XBRL concept for the period of the filing.
+	// The concept is written as us-gaap:Revenues or as its tag in the
+	// reports. Ex: us-gaap_Revenues
+	Fact(concept string) (Fact, error)
+
+	// Facts gets every fact read out of the filing, including the facts
+	// of the prior periods and of the concepts that are not collected
+	Facts() []Fact
 }
 
 // CompanyFolder interface used to get filing information about a company
@@ -66,6 +217,41 @@ type CompanyFolder interface {
 	// Filings gets a list of filings. Parallel fetch.
 	Filings(FilingType, ...time.Time) ([]Filing, error)
 
+	// FilingContext is Filing with a context used to cancel the fetch
+	// or set a deadline on it
+	FilingContext(context.Context, FilingType, time.Time) (Filing, error)
+
+	// FilingsContext is Filings with a context used to cancel all the
+	// parallel fetches or set a deadline on them
+	FilingsContext(context.Context, FilingType, ...time.Time) ([]Filing, error)
+
+	// FourthQuarter gets the fourth quarter of the fiscal year of the 10-K
+	// filed on the given date. There is no 10-Q for the fourth quarter so
+	// the quarter is made out of the 10-K and the 10-Q filings of the
+	// first three quarters of the year, which are fetched as needed. The
+	// flow data, like revenue, net income and cash flows, is the data of
+	// the year less the data of the three quarters and is marked as
+	// derived. Weighted average share counts can not be derived and are
+	// only set when the 10-K reports them for the fourth quarter. The
+	// balance sheet is the balance sheet of the 10-K.
+	FourthQuarter(time.Time) (Filing, error)
+
+	// FourthQuarterContext is FourthQuarter with a context used to cancel
+	// the fetches or set a deadline on them
+	FourthQuarterContext(context.Context, time.Time) (Filing, error)
+
+	// TTM gets the trailing twelve months as of the given date. The twelve
+	// months end with the latest 10-Q or 10-K filed by the date. After a
+	// 10-Q the flow data is the data of the 10-K of the previous fiscal
+	// year plus the year to date of the 10-Q less the year to date of the
+	// prior year, and is marked as derived. The balance sheet is the one
+	// of the latest filing. The filings needed are fetched as needed.
+	TTM(time.Time) (Filing, error)
+
+	// TTMContext is TTM with a context used to cancel the fetches or set
+	// a deadline on them
+	TTMContext(context.Context, time.Time) (Filing, error)
+
 	// SaveFolder persists the data from the company folder into a writer
 	// provided by the user. This stored info can be presented back to
 	// the fetcher (using CreateFolder API in fetcher) to recreate the
@@ -89,6 +275,50 @@ type FilingFetcher interface {
 	// User can provoder a store of edgar data previous stored
 	// by this package (using the Store function of the Company Folder)
 	// This function is used to avoid reparsing edgar data and reusing
-	// already parsed and stored information.
+	// already parsed and stored information. The CIK stored with the
+	// folder is used instead of looking up the ticker again.
 	CreateFolder(io.Reader, ...FilingType) (CompanyFolder, error)
+
+	// CompanyFolderContext is CompanyFolder with a context used to cancel
+	// the requests made to EDGAR or set a deadline on them
+	CompanyFolderContext(context.Context, string, ...FilingType) (CompanyFolder, error)
+
+	// CreateFolderContext is CreateFolder with a context used to cancel
+	// the requests made to EDGAR or set a deadline on them
+	CreateFolderContext(context.Context, io.Reader, ...FilingType) (CompanyFolder, error)
+
+	// CompanyFactsFolder creates a company folder out of the companyfacts
+	// document of the company published by the XBRL APIs of EDGAR. The
+	// filings of the given types reported in the document are filled in
+	// with the facts reported in them, without any request to EDGAR.
+	// Filings of all the supported types are collected when no type is
+	// given. Filings filed before the earliest year of the fetcher are
+	// not collected.
+	CompanyFactsFolder(io.Reader, ...FilingType) (CompanyFolder, error)
+
+	// LoadCompanyFactsFolder is CompanyFactsFolder reading the companyfacts
+	// document from a file. Ex: CIK0000320193.json out of the bulk
+	// companyfacts.zip of EDGAR
+	LoadCompanyFactsFolder(string, ...FilingType) (CompanyFolder, error)
+
+	// Frames gets the value of a metric reported by every company for a
+	// calendar period using the frames API of EDGAR. The period is in the
+	// format of the frames API. Ex: CY2023 for a year, CY2023Q1 for a
+	// quarter. Metrics of the balance sheet are reported as of the end of
+	// the period. Every XBRL concept that the metric is collected from is
+	// queried and a company gets the value of the preferred concept that
+	// it reported. Ex: us-gaap:Revenues before the revenue from contracts
+	// with customers. Money is only queried in USD, so companies that
+	// report in another currency, like most foreign private issuers, are
+	// left out.
+	Frames(Metric, string) ([]FrameValue, error)
+
+	// FramesContext is Frames with a context used to cancel the requests
+	// made to EDGAR or set a deadline on them
+	FramesContext(context.Context, Metric, string) ([]FrameValue, error)
+
+	// ReadFrame reads the values of a frames document of EDGAR, for a
+	// single concept, unit and period. The concept must be one that a
+	// metric is collected from.
+	ReadFrame(io.Reader) ([]FrameValue, error)
 }
@@ -0,0 +1,424 @@
+// Package edgartest provides a fake EDGAR website for testing users of the
+// edgar package without network access.
+//
+// The server serves the pages that the edgar package crawls: the CIK lookup
+// of a ticker, the list of filings of a company, the interactive viewer of
+// a filing, the filing summary and the R report pages of the filing. The
+// pages of a filing are read from files, usually pages saved from EDGAR
+// like the ones in the samples directory of the edgar package. Failures can
+// be injected for any page to test how the users of the package handle them.
+package edgartest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Failure is a failure mode injected into the pages served by the server
+type Failure int
+
+const (
+	// FailNone serves the page normally
+	FailNone Failure = iota
+	// FailTimeout never responds. The request hangs till the client gives up
+	FailTimeout
+	// FailServerError responds with a 500 Internal Server Error
+	FailServerError
+	// FailNotFound responds with a 404 Not Found
+	FailNotFound
+	// FailRateLimited responds with a 429 Too Many Requests
+	FailRateLimited
+	// FailTruncated sends half of the page and drops the connection
+	FailTruncated
+)
+
+// Filing is a filing served by the server
+type Filing struct {
+	// Type is the filing type. Ex: 10-K
+	Type string
+	// Filed is the date of filing in the YYYY-MM-DD format
+	Filed string
+	// Accession is the accession number of the filing. Ex: 0000320193-18-000100
+	Accession string
+	// ReportDate is the end of the period of the report in the YYYY-MM-DD format
+	ReportDate string
+	// PrimaryDocument is the name of the main document of the filing
+	PrimaryDocument string
+	// Viewer is the file with the interactive viewer page of the filing
+	Viewer string
+	// Reports are the files with the R pages of the filing by the number of
+	// the report. Reports that have no file are served as empty reports.
+	Reports map[int]string
+	// Instance is the file with the XBRL instance document of the filing.
+	// It is listed in the index of the documents of the filing.
+	Instance string
+	// Summary is the file with the FilingSummary.xml of the filing. Filings
+	// without a summary are identified by the menu of the viewer.
+	Summary string
+	// InlineXBRL is the file with the primary document of a filing made
+	// with inline XBRL. It is listed in the index along with an exhibit.
+	InlineXBRL string
+	// NoXBRL marks a filing made without XBRL financial data
+	NoXBRL bool
+}
+
+// Company is a company served by the server
+type Company struct {
+	Ticker  string
+	CIK     string
+	Filings []Filing
+}
+
+// Server is a fake EDGAR website
+type Server struct {
+	*httptest.Server
+	// RecentFilings is the number of filings listed in the recent filings
+	// of the submissions of a company. Older filings are paged into history
+	// files of RecentFilings each. Defaults to 1000.
+	RecentFilings int
+	mu            sync.Mutex
+	companies     map[string]*Company
+	frames        map[string]string
+	failures      map[string]Failure
+	requests      map[string]int
+	done          chan struct{}
+}
+
+// NewServer starts a fake EDGAR website with no companies
+func NewServer() *Server {
+	s := &Server{
+		RecentFilings: 1000,
+		companies:     make(map[string]*Company),
+		frames:        make(map[string]string),
+		failures:      make(map[string]Failure),
+		requests:      make(map[string]int),
+		done:          make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// NewSampleServer starts a fake EDGAR website that serves the AAPL filings
+// saved in the samples directory of the edgar package
+func NewSampleServer(samples string) *Server {
+	s := NewServer()
+	s.AddCompany(Company{
+		Ticker: "AAPL",
+		CIK:    "0000320193",
+		Filings: []Filing{
+			{
+				Type:      "10-Q",
+				Filed:     "2018-08-01",
+				Accession: "0000320193-18-000100",
+				// The primary document is the one with the inline XBRL
+				PrimaryDocument: "sample_10Q_ixbrl.htm",
+				Viewer:          filepath.Join(samples, "sample_10Q.html"),
+				Reports: map[int]string{
+					1: filepath.Join(samples, "sample_entity.html"),
+					2: filepath.Join(samples, "sample_ops.html"),
+					5: filepath.Join(samples, "sample_bs.html"),
+					7: filepath.Join(samples, "sample_cf.html"),
+				},
+				Instance:   filepath.Join(samples, "sample_10Q.xml"),
+				InlineXBRL: filepath.Join(samples, "sample_10Q_ixbrl.htm"),
+				Summary:    filepath.Join(samples, "sample_10Q_summary.xml"),
+			},
+			{
+				Type:      "10-K",
+				Filed:     "2015-10-28",
+				Accession: "0001193125-15-356351",
+				Viewer:    filepath.Join(samples, "sample_10K.html"),
+				Reports: map[int]string{
+					1: filepath.Join(samples, "sample_10K_entity.html"),
+					2: filepath.Join(samples, "sample_10K_ops.html"),
+					5: filepath.Join(samples, "sample_10K_bs.html"),
+					8: filepath.Join(samples, "sample_10K_cf.html"),
+				},
+			},
+		},
+	})
+	return s
+}
+
+// AddCompany adds a company and its filings to the server
+func (s *Server) AddCompany(c Company) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.companies[strings.ToUpper(c.Ticker)] = &c
+}
+
+// AddFrame adds a frames document served for the concept of the taxonomy
+// in the unit for the period. Ex: AddFrame("us-gaap", "Revenues", "USD",
+// "CY2019Q1", file) serves /api/xbrl/frames/us-gaap/Revenues/USD/CY2019Q1.json
+func (s *Server) AddFrame(taxonomy, tag, unit, period, file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frames[strings.Join([]string{taxonomy, tag, unit, period}, "/")+".json"] = file
+}
+
+// Fail injects a failure into every request whose path and query contains
+// the given pattern. FailNone removes the failure.
+func (s *Server) Fail(pattern string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == FailNone {
+		delete(s.failures, pattern)
+		return
+	}
+	s.failures[pattern] = f
+}
+
+// Requests returns the number of requests made so far whose path and
+// query contains the given pattern
+func (s *Server) Requests(pattern string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cnt := 0
+	for uri, n := range s.requests {
+		if strings.Contains(uri, pattern) {
+			cnt += n
+		}
+	}
+	return cnt
+}
+
+// Close releases the requests that are hanging and shuts down the server
+func (s *Server) Close() {
+	close(s.done)
+	s.Server.Close()
+}
+
+func (s *Server) failure(uri string) Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[uri]++
+	for pattern, f := range s.failures {
+		if strings.Contains(uri, pattern) {
+			return f
+		}
+	}
+	return FailNone
+}
+
+func (s *Server) company(id string) *Company {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.companies[strings.ToUpper(id)]; ok {
+		return c
+	}
+	for _, c := range s.companies {
+		if strings.TrimLeft(c.CIK, "0") == strings.TrimLeft(id, "0") {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	page, err := s.page(r)
+	switch s.failure(r.URL.RequestURI()) {
+	case FailTimeout:
+		select {
+		case <-r.Context().Done():
+		case <-s.done:
+		}
+		return
+	case FailServerError:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	case FailNotFound:
+		http.NotFound(w, r)
+		return
+	case FailRateLimited:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	case FailTruncated:
+		if err == nil {
+			w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+			w.Write(page[:len(page)/2])
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(page)
+}
+
+func (s *Server) page(r *http.Request) ([]byte, error) {
+	q := r.URL.Query()
+	switch {
+	case r.URL.Path == "/cgi-bin/browse-edgar" && q.Get("output") == "xml":
+		c := s.company(q.Get("CIK"))
+		if c == nil {
+			return nil, fmt.Errorf("unknown company %s", q.Get("CIK"))
+		}
+		return []byte(cikPage(c)), nil
+	case r.URL.Path == "/cgi-bin/browse-edgar":
+		c := s.company(q.Get("CIK"))
+		if c == nil {
+			return nil, fmt.Errorf("unknown company %s", q.Get("CIK"))
+		}
+		start, _ := strconv.Atoi(q.Get("start"))
+		count, err := strconv.Atoi(q.Get("count"))
+		if err != nil || count <= 0 {
+			count = 40
+		}
+		return []byte(queryPage(c, q.Get("type"), start, count)), nil
+	case r.URL.Path == "/cgi-bin/viewer":
+		f := s.filing(q.Get("accession_number"))
+		if f == nil || f.Viewer == "" {
+			return nil, fmt.Errorf("unknown filing %s", q.Get("accession_number"))
+		}
+		return ioutil.ReadFile(f.Viewer)
+	case strings.HasPrefix(r.URL.Path, "/Archives/edgar/data/"):
+		return s.report(r.URL.Path)
+	case strings.HasPrefix(r.URL.Path, "/submissions/"):
+		return s.submissions(strings.TrimPrefix(r.URL.Path, "/submissions/"))
+	case strings.HasPrefix(r.URL.Path, "/api/xbrl/frames/"):
+		s.mu.Lock()
+		file, ok := s.frames[strings.TrimPrefix(r.URL.Path, "/api/xbrl/frames/")]
+		s.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown frame %s", r.URL.Path)
+		}
+		return ioutil.ReadFile(file)
+	}
+	return nil, fmt.Errorf("unknown page %s", r.URL.Path)
+}
+
+func (s *Server) filing(accession string) *Filing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	accession = strings.Replace(accession, "-", "", -1)
+	for _, c := range s.companies {
+		for i := range c.Filings {
+			if strings.Replace(c.Filings[i].Accession, "-", "", -1) == accession {
+				return &c.Filings[i]
+			}
+		}
+	}
+	return nil
+}
+
+// report serves /Archives/edgar/data/<cik>/<accession>/R<num>.htm along
+// with the index, the summary and the documents of the filing
+func (s *Server) report(path string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/Archives/edgar/data/"), "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("unknown page %s", path)
+	}
+	f := s.filing(parts[1])
+	if f == nil {
+		return nil, fmt.Errorf("unknown filing %s", parts[1])
+	}
+	switch {
+	case parts[2] == "index.json":
+		return []byte(indexPage(f)), nil
+	case f.Instance != "" && parts[2] == filepath.Base(f.Instance):
+		return ioutil.ReadFile(f.Instance)
+	case f.Summary != "" && parts[2] == "FilingSummary.xml":
+		return ioutil.ReadFile(f.Summary)
+	case f.InlineXBRL != "" && parts[2] == filepath.Base(f.InlineXBRL):
+		return ioutil.ReadFile(f.InlineXBRL)
+	}
+	var num int
+	if _, err := fmt.Sscanf(parts[2], "R%d.htm", &num); err != nil {
+		return nil, fmt.Errorf("unknown report %s", parts[2])
+	}
+	if file, ok := f.Reports[num]; ok {
+		return ioutil.ReadFile(file)
+	}
+	return []byte(emptyReport), nil
+}
+
+func cikPage(c *Company) string {
+	return "<?xml version=\"1.0\" encoding=\"ISO-8859-1\" ?>\n" +
+		"<companyFilings><companyInfo><CIK>" + c.CIK + "</CIK>" +
+		"<name>" + c.Ticker + "</name></companyInfo></companyFilings>\n"
+}
+
+// queryPage lists count filings of the type starting from the start'th
+// filing, latest first
+func queryPage(c *Company, fileType string, start int, count int) string {
+	var filings []Filing
+	for _, f := range c.Filings {
+		if fileType == "" || strings.HasPrefix(f.Type, fileType) {
+			filings = append(filings, f)
+		}
+	}
+	sort.Slice(filings, func(i, j int) bool {
+		return filings[i].Filed > filings[j].Filed
+	})
+	if start > len(filings) {
+		start = len(filings)
+	}
+	filings = filings[start:]
+	if len(filings) > count {
+		filings = filings[:count]
+	}
+
+	cik := strings.TrimLeft(c.CIK, "0")
+	page := "<html><body><table class=\"tableFile2\" summary=\"Results\">\n" +
+		"<tr><th>Filings</th><th>Format</th><th>Description</th>" +
+		"<th>Filing Date</th><th>File/Film Number</th></tr>\n"
+	for _, f := range filings {
+		an := strings.Replace(f.Accession, "-", "", -1)
+		// Filings without XBRL have no interactive data button
+		interactive := ""
+		if !f.NoXBRL {
+			interactive = "<a href=\"/cgi-bin/viewer?action=view&amp;cik=" + cik + "&amp;accession_number=" + f.Accession + "&amp;xbrl_type=v\" id=\"interactiveDataBtn\">&nbsp;Interactive Data</a>"
+		}
+		page += "<tr><td nowrap=\"nowrap\">" + f.Type + "</td>" +
+			"<td nowrap=\"nowrap\"><a href=\"/Archives/edgar/data/" + cik + "/" + an + "/" + f.Accession + "-index.htm\" id=\"documentsbutton\">&nbsp;Documents</a>&nbsp; " +
+			interactive + "</td>" +
+			"<td class=\"small\">Acc-no: " + f.Accession + "</td>" +
+			"<td>" + f.Filed + "</td>" +
+			"<td nowrap=\"nowrap\">000-00000</td></tr>\n"
+	}
+	return page + "</table></body></html>\n"
+}
+
+// indexPage lists the documents of the filing in the format of the
+// index.json of a filing on EDGAR
+func indexPage(f *Filing) string {
+	var items []string
+	for num := range f.Reports {
+		items = append(items, fmt.Sprintf(`{"name":"R%d.htm","type":"text.gif"}`, num))
+	}
+	sort.Strings(items)
+	items = append(items, `{"name":"FilingSummary.xml","type":"text.gif"}`)
+	if f.Instance != "" {
+		base := strings.TrimSuffix(filepath.Base(f.Instance), ".xml")
+		items = append(items,
+			`{"name":"`+base+`_cal.xml","type":"text.gif"}`,
+			`{"name":"`+base+`.xml","type":"text.gif"}`,
+			`{"name":"`+base+`_pre.xml","type":"text.gif"}`)
+	}
+	if f.InlineXBRL != "" {
+		size := 0
+		if info, err := os.Stat(f.InlineXBRL); err == nil {
+			size = int(info.Size())
+		}
+		items = append(items,
+			`{"name":"ex31.htm","type":"text.gif","size":"1024"}`,
+			fmt.Sprintf(`{"name":"%s","type":"text.gif","size":"%d"}`, filepath.Base(f.InlineXBRL), size))
+	}
+	an := strings.Replace(f.Accession, "-", "", -1)
+	return `{"directory":{"name":"` + an + `","item":[` + strings.Join(items, ",") + `]}}` + "\n"
+}
+
+var emptyReport = `<html><body><table class="report" border="0" cellspacing="2">
+<tr><th class="tl" colspan="1" rowspan="1"><div><strong>Report - USD ($)<br> $ in Millions</strong></div></th></tr>
+</table></body></html>
+`
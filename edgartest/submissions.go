@@ -0,0 +1,107 @@
+package edgartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type submissionFilings struct {
+	AccessionNumber []string `json:"accessionNumber"`
+	FilingDate      []string `json:"filingDate"`
+	ReportDate      []string `json:"reportDate"`
+	Form            []string `json:"form"`
+	PrimaryDocument []string `json:"primaryDocument"`
+	IsXBRL          []int    `json:"isXBRL"`
+	IsInlineXBRL    []int    `json:"isInlineXBRL"`
+}
+
+type submissionFile struct {
+	Name        string `json:"name"`
+	FilingCount int    `json:"filingCount"`
+	FilingFrom  string `json:"filingFrom"`
+	FilingTo    string `json:"filingTo"`
+}
+
+func newSubmissionFilings(filings []Filing) submissionFilings {
+	var s submissionFilings
+	for _, f := range filings {
+		s.AccessionNumber = append(s.AccessionNumber, f.Accession)
+		s.FilingDate = append(s.FilingDate, f.Filed)
+		s.ReportDate = append(s.ReportDate, f.ReportDate)
+		s.Form = append(s.Form, f.Type)
+		s.PrimaryDocument = append(s.PrimaryDocument, f.PrimaryDocument)
+		s.IsXBRL = append(s.IsXBRL, flagValue(!f.NoXBRL))
+		s.IsInlineXBRL = append(s.IsInlineXBRL, flagValue(f.InlineXBRL != ""))
+	}
+	return s
+}
+
+// submissions serves the submissions document of a company named
+// CIK##########.json and its history files named
+// CIK##########-submissions-###.json
+func (s *Server) submissions(name string) ([]byte, error) {
+	var cik string
+	page := 0
+	if strings.Contains(name, "-submissions-") {
+		if _, err := fmt.Sscanf(name, "CIK%10s-submissions-%03d.json", &cik, &page); err != nil {
+			return nil, fmt.Errorf("unknown submissions %s", name)
+		}
+	} else if _, err := fmt.Sscanf(name, "CIK%10s.json", &cik); err != nil {
+		return nil, fmt.Errorf("unknown submissions %s", name)
+	}
+	c := s.company(cik)
+	if c == nil {
+		return nil, fmt.Errorf("unknown company %s", cik)
+	}
+
+	filings := append([]Filing(nil), c.Filings...)
+	sort.Slice(filings, func(i, j int) bool {
+		return filings[i].Filed > filings[j].Filed
+	})
+	size := s.RecentFilings
+	if size <= 0 {
+		size = 1000
+	}
+	var pages [][]Filing
+	for len(filings) > size {
+		pages = append(pages, filings[:size])
+		filings = filings[size:]
+	}
+	pages = append(pages, filings)
+	if page >= len(pages) {
+		return nil, fmt.Errorf("unknown submissions %s", name)
+	}
+	if page > 0 {
+		return json.Marshal(newSubmissionFilings(pages[page]))
+	}
+
+	var files []submissionFile
+	for i, p := range pages[1:] {
+		files = append(files, submissionFile{
+			Name:        fmt.Sprintf("CIK%s-submissions-%03d.json", cik, i+1),
+			FilingCount: len(p),
+			FilingFrom:  p[len(p)-1].Filed,
+			FilingTo:    p[0].Filed,
+		})
+	}
+	doc := map[string]interface{}{
+		"cik":     strings.TrimLeft(c.CIK, "0"),
+		"name":    c.Ticker,
+		"tickers": []string{c.Ticker},
+		"filings": map[string]interface{}{
+			"recent": newSubmissionFilings(pages[0]),
+			"files":  files,
+		},
+	}
+	return json.Marshal(doc)
+}
+
+// flagValue writes a boolean as the 0 or 1 of the submissions API
+func flagValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package edgar
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPError is returned when EDGAR responds to a request with a status
+// that is not a success. More specific errors embed the HTTPError and
+// unwrap to it so that errors.As finds the HTTPError of any of them.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Query to SEC page %s failed with status %d %s",
+		e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// RateLimitedError is returned when EDGAR throttles the requests made by
+// the fetcher. RetryAfter is the wait time requested by EDGAR, if any.
+type RateLimitedError struct {
+	HTTPError
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return &e.HTTPError
+}
+
+// NotFoundError is returned when the requested page does not exist on EDGAR
+type NotFoundError struct {
+	HTTPError
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return &e.HTTPError
+}
+
+// ServerError is returned when EDGAR fails to serve a request
+type ServerError struct {
+	HTTPError
+}
+
+func (e *ServerError) Unwrap() error {
+	return &e.HTTPError
+}
+
+// newHTTPError creates the error for an unsuccessful response
+func newHTTPError(url string, resp *http.Response) error {
+	base := HTTPError{URL: url, StatusCode: resp.StatusCode}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitedError{
+			HTTPError:  base,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return &NotFoundError{HTTPError: base}
+	case resp.StatusCode >= 500:
+		return &ServerError{HTTPError: base}
+	}
+	return &base
+}
+
+// ErrNoUserAgent is returned for the requests of a fetcher created without
+// a User-Agent. The SEC blocks the requests that do not declare who makes
+// them.
+var ErrNoUserAgent = errors.New("No User-Agent set for the requests to EDGAR. Set one with WithUserAgent")
+
+// errNoFinancialData is returned for filings made without XBRL financial
+// data, like the amendments that only add Part III of a 10-K
+var errNoFinancialData = errors.New("No XBRL financial data in the filing")
+
+// filingsError collects the errors of a parallel fetch of filings
+type filingsError struct {
+	errs []error
+}
+
+func (e *filingsError) Error() string {
+	errString := "Failed to retrieve some filings: \n"
+	for _, err := range e.errs {
+		errString = errString + err.Error() + "\n"
+	}
+	return errString
+}
+
+func (e *filingsError) Unwrap() []error {
+	return e.errs
+}
@@ -1,14 +1,184 @@
 package edgar
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
 type fetcher struct {
-	folders map[string]*company
+	folders  map[string]*company
+	client   *client
+	resolver CIKResolver
+}
+
+// Option configures a filing fetcher created with NewFilingFetcher
+type Option func(*fetcher)
+
+// WithHTTPClient sets the HTTP client used for all requests made to EDGAR
+func WithHTTPClient(c *http.Client) Option {
+	return func(f *fetcher) {
+		if c != nil {
+			f.client.http = c
+		}
+	}
+}
+
+// WithTransport sets the round tripper used for all requests made to EDGAR
+func WithTransport(rt http.RoundTripper) Option {
+	return func(f *fetcher) {
+		f.client.http = &http.Client{Transport: rt}
+	}
+}
+
+// WithBaseURL overrides the EDGAR site that the fetcher talks to.
+// Defaults to https://www.sec.gov/
+func WithBaseURL(url string) Option {
+	return func(f *fetcher) {
+		f.client.baseURL = url
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+// The SEC requires the User-Agent to declare the company or individual
+// making the requests along with a contact email address, for example
+// "Sample Company Name AdminContact@samplecompany.com". There is no default
+// and the requests of a fetcher without one fail with ErrNoUserAgent.
+func WithUserAgent(ua string) Option {
+	return func(f *fetcher) {
+		f.client.userAgent = ua
+	}
+}
+
+// WithRateLimit sets the maximum number of requests per second made by
+// the fetcher across all its folders and parallel fetches. The SEC allows
+// at most 10 requests per second which is also the default.
+// A rate of 0 or less disables rate limiting.
+func WithRateLimit(requestsPerSecond float64) Option {
+	return func(f *fetcher) {
+		f.client.limiter = newRateLimiter(requestsPerSecond)
+	}
+}
+
+// WithRetries sets the number of times a request is retried when EDGAR
+// throttles the request or fails with a server error. Defaults to 3.
+func WithRetries(retries int) Option {
+	return func(f *fetcher) {
+		if retries < 0 {
+			retries = 0
+		}
+		f.client.retry.retries = retries
+	}
+}
+
+// WithBackoff sets the wait before the first retry and the maximum wait
+// between retries. The wait doubles on every retry and is jittered.
+// A Retry-After header sent by EDGAR is honoured over the backoff.
+func WithBackoff(minWait, maxWait time.Duration) Option {
+	return func(f *fetcher) {
+		if minWait > 0 {
+			f.client.retry.minBackoff = minWait
+		}
+		if maxWait >= minWait {
+			f.client.retry.maxBackoff = maxWait
+		}
+	}
+}
+
+// WithCache stores every page fetched from EDGAR in the given directory
+// and serves later requests for the same page from disk. Pages of filings
+// never change and are cached forever. Pages that change with every new
+// filing, like the list of filings of a company, are cached for the ttl.
+// A ttl of 0 or less does not cache such pages at all.
+func WithCache(dir string, ttl time.Duration) Option {
+	return func(f *fetcher) {
+		f.client.cache = &diskCache{dir: dir, ttl: ttl}
+	}
+}
+
+// WithEarliestYear sets the earliest year of filings collected into the
+// folders. Filings filed before this year are ignored. Defaults to 2012.
+func WithEarliestYear(year int) Option {
+	return func(f *fetcher) {
+		f.client.threshold = year
+	}
+}
+
+// WithDataURL overrides the EDGAR site that serves the EDGAR APIs, like
+// the submissions of a company. Defaults to https://data.sec.gov/
+func WithDataURL(url string) Option {
+	return func(f *fetcher) {
+		f.client.dataURL = url
+	}
+}
+
+// WithDiscovery sets the backend used to discover the filings of a
+// company. Defaults to DiscoverQueryPage
+func WithDiscovery(d Discovery) Option {
+	return func(f *fetcher) {
+		f.client.discovery = d
+	}
+}
+
+// WithParser sets the backend used to parse the financial data of the
+// filings. Defaults to ParseRendered
+func WithParser(p Parser) Option {
+	return func(f *fetcher) {
+		f.client.parser = p
+	}
+}
+
+// WithAmendedFilings makes CompanyFolder.Filing return the latest amended
+// view of a filing. The data restated in the amendments of the filing
+// overrides the data of the original filing. The amendments are fetched
+// along with the filing. Amendments themselves are fetched as is.
+func WithAmendedFilings(amended bool) Option {
+	return func(f *fetcher) {
+		f.client.amended = amended
+	}
+}
+
+// WithCIKResolver sets the resolver used to look up the CIK of a ticker.
+// EDGAR is queried only for the tickers that the resolver does not know.
+func WithCIKResolver(r CIKResolver) Option {
+	return func(f *fetcher) {
+		f.resolver = r
+	}
+}
+
+// WithMetricMappings adds mappings of XBRL tags to metrics, and custom
+// metrics, that are used for the filings parsed by the fetcher. Mappings
+// added later win over earlier mappings of the same tags. Mappings made in
+// Go are checked with their Validate method before they are added.
+func WithMetricMappings(m *MetricMappings) Option {
+	return func(f *fetcher) {
+		if m == nil {
+			return
+		}
+		merged := &MetricMappings{}
+		if f.client.mappings != nil {
+			merged.merge(f.client.mappings)
+		}
+		merged.merge(m)
+		f.client.mappings = merged
+	}
+}
+
+// lookupCIK gets the CIK of the ticker from the resolver of the fetcher
+// and falls back to querying EDGAR
+func (f *fetcher) lookupCIK(ctx context.Context, ticker string) (string, error) {
+	if f.resolver != nil {
+		if info, err := f.resolver.LookupTicker(ticker); err == nil {
+			return info.CIK, nil
+		}
+	}
+	return f.client.getCompanyCIK(ctx, ticker)
 }
 
 // CompanyFolder creates a new folder and populates it with the filing filing
@@ -16,50 +186,148 @@ type fetcher struct {
 func (f *fetcher) CompanyFolder(
 	ticker string,
 	fileTypes ...FilingType) (CompanyFolder, error) {
+	return f.CompanyFolderContext(context.Background(), ticker, fileTypes...)
+}
+
+// CompanyFolderContext is CompanyFolder with a context that applies to all
+// the requests made to populate the folder
+func (f *fetcher) CompanyFolderContext(
+	ctx context.Context,
+	ticker string,
+	fileTypes ...FilingType) (CompanyFolder, error) {
 
 	comp, ok := f.folders[ticker]
 	if !ok {
-		comp = newCompany(ticker)
-		if comp.cik == "" {
+		var err error
+		comp = newCompany(f.client, ticker)
+		comp.CentralIndexKey, err = f.lookupCIK(ctx, ticker)
+		if err != nil {
+			return nil, err
+		}
+		if comp.CentralIndexKey == "" {
 			return nil, errors.New("Could not find the CIK for the given ticker")
 		}
-		f.folders[ticker] = comp
-		for _, t := range fileTypes {
-			comp.addFilingLinks(t, getFilingLinks(ticker, t))
+		links, err := f.client.getFilingLinks(ctx, ticker, comp.CentralIndexKey, fileTypes...)
+		if err != nil {
+			return nil, err
+		}
+		for t, l := range links {
+			comp.addFilingLinks(t, l)
 		}
+		f.folders[ticker] = comp
 	}
 	return comp, nil
 }
 
 // CreateFolder Reads from the reader into a new company folder
 func (f *fetcher) CreateFolder(
+	r io.Reader,
+	fileTypes ...FilingType) (CompanyFolder, error) {
+	return f.CreateFolderContext(context.Background(), r, fileTypes...)
+}
+
+// CreateFolderContext is CreateFolder with a context that applies to all
+// the requests made to populate the folder
+func (f *fetcher) CreateFolderContext(
+	ctx context.Context,
 	r io.Reader,
 	fileTypes ...FilingType) (CompanyFolder, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	c := newCompany("")
+	c := newCompany(f.client, "")
 
 	err = json.Unmarshal(b, c)
 	if err != nil {
 		return nil, err
 	}
-	// Populate the CIK
-	c.cik = getCompanyCIK(c.Ticker())
-	if c.cik == "" {
+	// Populate the CIK unless it was saved with the folder
+	if c.CentralIndexKey == "" {
+		c.CentralIndexKey, err = f.lookupCIK(ctx, c.Ticker())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.CentralIndexKey == "" {
 		return nil, errors.New("Could not find the CIK for the given ticker")
 	}
 
-	f.folders[c.Ticker()] = c
 	// Get all the latest links for all the filing types
-	for _, key := range fileTypes {
-		c.addFilingLinks(key, getFilingLinks(c.Ticker(), key))
+	links, err := f.client.getFilingLinks(ctx, c.Ticker(), c.CentralIndexKey, fileTypes...)
+	if err != nil {
+		return nil, err
+	}
+	for key, l := range links {
+		c.addFilingLinks(key, l)
+	}
+	f.folders[c.Ticker()] = c
+	return c, nil
+}
+
+// CompanyFactsFolder creates a folder out of the companyfacts document of
+// a company. The folder is named after the ticker of the company when the
+// resolver of the fetcher knows the company.
+func (f *fetcher) CompanyFactsFolder(
+	r io.Reader,
+	fileTypes ...FilingType) (CompanyFolder, error) {
+
+	cf, err := companyFactsParser(r)
+	if err != nil {
+		return nil, err
+	}
+	ticker := cf.EntityName
+	if f.resolver != nil {
+		if info, err := f.resolver.LookupCIK(strconv.Itoa(cf.CIK)); err == nil && info.Ticker != "" {
+			ticker = info.Ticker
+		}
 	}
+	c := newFactsCompany(f.client, ticker, cf, fileTypes...)
+	f.folders[ticker] = c
 	return c, nil
 }
 
-// NewFilingFetcher creates a new empty filing fetcher
-func NewFilingFetcher() FilingFetcher {
-	return &fetcher{folders: make(map[string]*company)}
+// LoadCompanyFactsFolder creates a folder out of a companyfacts document
+// saved in a file
+func (f *fetcher) LoadCompanyFactsFolder(
+	path string,
+	fileTypes ...FilingType) (CompanyFolder, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return f.CompanyFactsFolder(file, fileTypes...)
+}
+
+// Frames gets the value of a metric reported by every company for a
+// calendar period
+func (f *fetcher) Frames(metric Metric, period string) ([]FrameValue, error) {
+	return f.FramesContext(context.Background(), metric, period)
+}
+
+// FramesContext is Frames with a context that applies to all the requests
+// made for the frames
+func (f *fetcher) FramesContext(ctx context.Context, metric Metric, period string) ([]FrameValue, error) {
+	return f.client.getFrames(ctx, metric, period, f.resolver)
+}
+
+// ReadFrame reads the values of a frames document. The companies are
+// mapped to their tickers using the resolver of the fetcher.
+func (f *fetcher) ReadFrame(r io.Reader) ([]FrameValue, error) {
+	return readFrame(r, f.resolver)
+}
+
+// NewFilingFetcher creates a new empty filing fetcher. The fetcher needs
+// a User-Agent set with WithUserAgent to make requests to EDGAR.
+func NewFilingFetcher(opts ...Option) FilingFetcher {
+	f := &fetcher{
+		folders: make(map[string]*company),
+		client:  newClient(),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
@@ -0,0 +1,303 @@
+package edgar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testUserAgent is the User-Agent of the fetchers of the tests
+const testUserAgent = "Test Company test@example.com"
+
+func TestUserAgentAndRateLimit(t *testing.T) {
+	var m sync.Mutex
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		agents = append(agents, r.Header.Get("User-Agent"))
+		m.Unlock()
+		w.Write([]byte("<companyInfo><CIK>0000320193</CIK></companyInfo>"))
+	}))
+	defer server.Close()
+
+	f := NewFilingFetcher(
+		WithBaseURL(server.URL),
+		WithUserAgent(testUserAgent),
+		WithRateLimit(20)).(*fetcher)
+
+	// The bucket starts full with a burst of 20 requests. The next 10
+	// requests need to wait for the bucket to refill at 20 per second.
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cik, err := f.client.getCompanyCIK(context.Background(), "AAPL"); err != nil || cik != "0000320193" {
+				t.Error("Incorrect CIK fetched ", cik, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Error("Requests were not rate limited: ", elapsed)
+	}
+	for _, ua := range agents {
+		if ua != testUserAgent {
+			t.Error("Incorrect User-Agent sent: ", ua)
+		}
+	}
+
+	// There is no default User-Agent
+	requests := len(agents)
+	f = NewFilingFetcher(WithBaseURL(server.URL)).(*fetcher)
+	if _, err := f.client.getCompanyCIK(context.Background(), "AAPL"); !errors.Is(err, ErrNoUserAgent) {
+		t.Error("Expected an error without a User-Agent ", err)
+	}
+	if len(agents) != requests {
+		t.Error("Request made without a User-Agent")
+	}
+}
+
+func TestRetryAndHTTPErrors(t *testing.T) {
+	var m sync.Mutex
+	attempts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		attempts[r.URL.Path]++
+		cnt := attempts[r.URL.Path]
+		m.Unlock()
+		switch r.URL.Path {
+		case "/flaky":
+			if cnt < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/throttled":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/down":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := NewFilingFetcher(
+		WithBaseURL(server.URL),
+		WithUserAgent(testUserAgent),
+		WithRetries(2),
+		WithBackoff(time.Millisecond, 5*time.Millisecond)).(*fetcher)
+
+	page, err := f.client.getPage(context.Background(), f.client.url("flaky"))
+	if err != nil {
+		t.Error("Failed to retry a flaky page: ", err)
+	} else {
+		page.Close()
+	}
+
+	_, err = f.client.getPage(context.Background(), f.client.url("throttled"))
+	if _, ok := err.(*RateLimitedError); !ok {
+		t.Error("Expected a rate limited error: ", err)
+	}
+	if attempts["/throttled"] != 3 {
+		t.Error("Incorrect number of attempts for a throttled page ", attempts["/throttled"])
+	}
+
+	_, err = f.client.getPage(context.Background(), f.client.url("down"))
+	if e, ok := err.(*ServerError); !ok || e.StatusCode != http.StatusInternalServerError {
+		t.Error("Expected a server error: ", err)
+	}
+
+	_, err = f.client.getPage(context.Background(), f.client.url("missing"))
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Error("Expected a not found error: ", err)
+	}
+	// Every specific error is an HTTPError as well
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Error("Expected the HTTP error of a not found error: ", err)
+	}
+	for _, e := range []error{
+		&RateLimitedError{HTTPError: HTTPError{StatusCode: http.StatusTooManyRequests}},
+		&ServerError{HTTPError: HTTPError{StatusCode: http.StatusBadGateway}},
+		fmt.Errorf("Wrapped: %w", &NotFoundError{HTTPError: HTTPError{StatusCode: http.StatusGone}}),
+	} {
+		if !errors.As(e, &httpErr) || httpErr.StatusCode == 0 {
+			t.Error("Expected the HTTP error of ", e)
+		}
+	}
+	if attempts["/missing"] != 1 {
+		t.Error("Not found page should not be retried ", attempts["/missing"])
+	}
+
+	// The error should make it to the user of the company folder
+	c := newCompany(f.client, "AAPL")
+	c.addFilingLinks(FilingType10K, map[string]filingLink{"2018-11-05": newFilingLink(FilingType10K, "2018-11-05", "/missing")})
+	_, err = c.Filing(FilingType10K, time.Time(getDate("2018-11-05")))
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Error("Expected a not found error from the folder: ", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("120"); d != 2*time.Minute {
+		t.Error("Incorrect Retry-After in seconds ", d)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 59*time.Minute || d > time.Hour {
+		t.Error("Incorrect Retry-After as a date ", d)
+	}
+	p := retryPolicy{retries: 1, minBackoff: time.Millisecond, maxBackoff: time.Second}
+	err := &RateLimitedError{RetryAfter: 10 * time.Second}
+	if d := p.backoff(err, 0); d != 10*time.Second {
+		t.Error("Retry-After not honoured in backoff ", d)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	f := NewFilingFetcher(WithBaseURL(server.URL), WithUserAgent(testUserAgent)).(*fetcher)
+	c := newCompany(f.client, "AAPL")
+	c.addFilingLinks(FilingType10Q, map[string]filingLink{
+		"2018-08-01": newFilingLink(FilingType10Q, "2018-08-01", "/viewer1"),
+		"2018-05-02": newFilingLink(FilingType10Q, "2018-05-02", "/viewer2"),
+		"2018-02-02": newFilingLink(FilingType10Q, "2018-02-02", "/viewer3"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	filings, err := c.FilingsContext(ctx, FilingType10Q, c.AvailableFilings(FilingType10Q)...)
+	if time.Since(start) > 2*time.Second {
+		t.Error("Parallel fetch was not cancelled in time")
+	}
+	if len(filings) != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected the deadline to be exceeded: ", err)
+	}
+
+	_, err = f.CompanyFolderContext(ctx, "AAPL", FilingType10Q)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected the deadline to be exceeded for the folder: ", err)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	var m sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		hits[r.URL.Path]++
+		m.Unlock()
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "edgar-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	get := func(f *fetcher, path string) string {
+		page, err := f.client.getPage(context.Background(), f.client.url(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer page.Close()
+		b, _ := ioutil.ReadAll(page)
+		return string(b)
+	}
+
+	for i := 0; i < 2; i++ {
+		// A new fetcher for every run of the same pages
+		f := NewFilingFetcher(WithBaseURL(server.URL), WithUserAgent(testUserAgent), WithCache(dir, 0)).(*fetcher)
+		if page := get(f, "/Archives/edgar/data/320193/000032019318000100/R2.htm"); page != "page /Archives/edgar/data/320193/000032019318000100/R2.htm" {
+			t.Error("Incorrect archived page ", page)
+		}
+		if page := get(f, "/cgi-bin/browse-edgar?action=getcompany"); page != "page /cgi-bin/browse-edgar" {
+			t.Error("Incorrect query page ", page)
+		}
+	}
+	if hits["/Archives/edgar/data/320193/000032019318000100/R2.htm"] != 1 {
+		t.Error("Archived page was not served from the cache")
+	}
+	if hits["/cgi-bin/browse-edgar"] != 2 {
+		t.Error("Query page should not be cached without a ttl")
+	}
+
+	f := NewFilingFetcher(WithBaseURL(server.URL), WithUserAgent(testUserAgent), WithCache(dir, time.Hour)).(*fetcher)
+	get(f, "/cgi-bin/browse-edgar?action=getcompany")
+	get(f, "/cgi-bin/browse-edgar?action=getcompany")
+	if hits["/cgi-bin/browse-edgar"] != 3 {
+		t.Error("Query page was not served from the cache within the ttl")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("page " + r.URL.RequestURI()))
+	}))
+
+	dir, err := ioutil.TempDir("", "edgar-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	get := func(f *fetcher, path string) (string, error) {
+		page, err := f.client.getPage(context.Background(), f.client.url(path))
+		if err != nil {
+			return "", err
+		}
+		defer page.Close()
+		b, _ := ioutil.ReadAll(page)
+		return string(b), nil
+	}
+
+	rec := NewFilingFetcher(WithBaseURL(server.URL), WithUserAgent(testUserAgent),
+		WithTransport(NewRecordingTransport(dir, nil))).(*fetcher)
+	get(rec, "/Archives/edgar/data/320193/R2.htm")
+	get(rec, "/cgi-bin/browse-edgar?action=getcompany&CIK=AAPL")
+	get(rec, "/missing")
+	server.Close()
+
+	// Replay against the default EDGAR site without any network access
+	play := NewFilingFetcher(WithTransport(NewReplayTransport(dir)), WithUserAgent(testUserAgent), WithRateLimit(0)).(*fetcher)
+	if page, err := get(play, "/Archives/edgar/data/320193/R2.htm"); err != nil || page != "page /Archives/edgar/data/320193/R2.htm" {
+		t.Error("Incorrect replay of a recorded page ", page, err)
+	}
+	if page, err := get(play, "/cgi-bin/browse-edgar?action=getcompany&CIK=AAPL"); err != nil || page != "page /cgi-bin/browse-edgar?action=getcompany&CIK=AAPL" {
+		t.Error("Incorrect replay of a recorded query ", page, err)
+	}
+	if _, err := get(play, "/missing"); err == nil {
+		t.Error("Recorded status was not replayed")
+	} else if _, ok := err.(*NotFoundError); !ok {
+		t.Error("Incorrect error replayed ", err)
+	}
+	if _, err := get(play, "/Archives/edgar/data/320193/R3.htm"); err == nil {
+		t.Error("Replay served a page that was not recorded")
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"errors"
 	"log"
 	"reflect"
+	"sort"
 	"time"
 )
 
 type filing struct {
-	Company string           `json:"Company"`
-	Date    Timestamp        `json:"Report date"`
-	FinData *financialReport `json:"Financial Data"`
+	Company  string           `json:"Company"`
+	Date     Timestamp        `json:"Report date"`
+	Original *Timestamp       `json:"Amends,omitempty"`
+	Merged   []Timestamp      `json:"Amendments,omitempty"`
+	FinData  *financialReport `json:"Financial Data"`
 }
 
 func (f filing) String() string {
@@ -41,6 +44,49 @@ func (f *filing) Type() (FilingType, error) {
 	return "", errors.New(f.filingErrorString())
 }
 
+func (f *filing) IsAmendment() bool {
+	return f.FinData != nil && isAmendment(f.FinData.DocType)
+}
+
+func (f *filing) Amends() (time.Time, error) {
+	if !f.IsAmendment() {
+		return time.Time{}, errors.New("Filing on " + getDateString(f.FiledOn()) + " is not an amendment")
+	}
+	if f.Original == nil {
+		return time.Time{}, errors.New("Could not find the filing amended by the filing on " + getDateString(f.FiledOn()))
+	}
+	return time.Time(*f.Original), nil
+}
+
+func (f *filing) Amendments() []time.Time {
+	var ret []time.Time
+	for _, ts := range f.Merged {
+		ret = append(ret, time.Time(ts))
+	}
+	return ret
+}
+
+func (f *filing) PeriodEnd() (time.Time, error) {
+	if f.FinData != nil && f.FinData.Entity != nil && f.FinData.Entity.PeriodEnd != nil {
+		return time.Time(*f.FinData.Entity.PeriodEnd), nil
+	}
+	return time.Time{}, errors.New(f.filingErrorString() + "Period End")
+}
+
+func (f *filing) FiscalYear() (int, error) {
+	if f.FinData != nil && f.FinData.Entity != nil && f.FinData.Entity.FiscalYear != 0 {
+		return f.FinData.Entity.FiscalYear, nil
+	}
+	return 0, errors.New(f.filingErrorString() + "Fiscal Year")
+}
+
+func (f *filing) FiscalPeriod() (string, error) {
+	if f.FinData != nil && f.FinData.Entity != nil && f.FinData.Entity.FiscalPeriod != "" {
+		return f.FinData.Entity.FiscalPeriod, nil
+	}
+	return "", errors.New(f.filingErrorString() + "Fiscal Period")
+}
+
 func (f *filing) ShareCount() (float64, error) {
 	if f.FinData != nil && f.FinData.Entity != nil {
 		if isCollectedDataSet(f.FinData.Entity, "ShareCount") {
@@ -298,5 +344,116 @@ func (f *filing) CollectedData() []string {
 	ret = append(ret, eval(f.FinData.Cf)...)
 	ret = append(ret, eval(f.FinData.Ops)...)
 
+	var custom []string
+	for fin := range f.FinData.Custom {
+		custom = append(custom, string(fin))
+	}
+	sort.Strings(custom)
+	return append(ret, custom...)
+}
+
+func (f *filing) Value(metric Metric) (float64, error) {
+	if f.FinData != nil {
+		if val, ok := f.FinData.value(finDataType(metric)); ok {
+			// Dividend is recorded as an expense and is -ve. Hence reversing sign
+			if metric == MetricDividend {
+				val *= -1
+			}
+			return val, nil
+		}
+	}
+	return 0, errors.New(f.filingErrorString() + string(metric))
+}
+
+func (f *filing) PeriodValues(metric Metric) []PeriodValue {
+	var ret []PeriodValue
+	if f.FinData == nil {
+		return ret
+	}
+	for _, p := range f.FinData.Periods {
+		if p.Data != finDataType(metric) {
+			continue
+		}
+		v := PeriodValue{
+			Metric: metric,
+			End:    time.Time(p.End),
+			Months: p.Months,
+			Value:  p.Value,
+		}
+		// Dividend is recorded as an expense and is -ve. Hence reversing sign
+		if p.Data == finDataDividend {
+			v.Value *= -1
+		}
+		ret = append(ret, v)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if !ret[i].End.Equal(ret[j].End) {
+			return ret[i].End.After(ret[j].End)
+		}
+		return ret[i].Months < ret[j].Months
+	})
 	return ret
 }
+
+func (f *filing) IsDerived(metric Metric) bool {
+	return f.FinData != nil && f.FinData.isDerived(finDataType(metric))
+}
+
+func (f *filing) Facts() []Fact {
+	var ret []Fact
+	if f.FinData == nil {
+		return ret
+	}
+	for _, v := range f.FinData.Facts {
+		ret = append(ret, Fact{
+			Concept: v.Concept,
+			Value:   v.Value,
+			Unit:    v.Unit,
+			Scale:   v.Scale,
+			End:     time.Time(v.End),
+			Months:  v.Months,
+			Report:  v.Report,
+		})
+	}
+	return ret
+}
+
+// Fact picks the fact of the concept ending with the period of the filing.
+// The quarter is picked in quarterly filings and the longest period in
+// annual filings. The latest fact is picked when none ends with the period.
+func (f *filing) Fact(concept string) (Fact, error) {
+	concept = conceptTag(concept)
+	var facts []Fact
+	for _, v := range f.Facts() {
+		if v.Concept == concept {
+			facts = append(facts, v)
+		}
+	}
+	if len(facts) == 0 {
+		return Fact{}, errors.New("Fact not found: " + concept)
+	}
+	end := time.Time(f.FinData.periodEnd())
+	quarterly := originalType(f.FinData.DocType) == FilingType10Q
+	rank := func(v Fact) int {
+		if !v.End.Equal(end) {
+			return 2
+		}
+		if !quarterly || v.Months == 3 {
+			return 0
+		}
+		return 1
+	}
+	sort.SliceStable(facts, func(i, j int) bool {
+		if ri, rj := rank(facts[i]), rank(facts[j]); ri != rj {
+			return ri < rj
+		}
+		if !facts[i].End.Equal(facts[j].End) {
+			return facts[i].End.After(facts[j].End)
+		}
+		if quarterly {
+			return facts[i].Months < facts[j].Months
+		}
+		return facts[i].Months > facts[j].Months
+	})
+	return facts[0], nil
+}
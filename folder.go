@@ -1,21 +1,88 @@
 package edgar
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// filingLink is what is known about a filing before the filing is fetched
+type filingLink struct {
+	Link            string
+	Accession       string
+	Form            string
+	FilingDate      string
+	ReportDate      string
+	PrimaryDocument string
+	IsXBRL          bool
+	IsInlineXBRL    bool
+}
+
+func newFilingLink(fileType FilingType, filed string, link string) filingLink {
+	l := filingLink{
+		Link:       link,
+		Form:       string(fileType),
+		FilingDate: filed,
+		IsXBRL:     true,
+	}
+	if u, err := url.Parse(link); err == nil {
+		l.Accession = u.Query().Get("accession_number")
+	}
+	return l
+}
+
+// isAmendment tells if the filing type is an amendment. Ex: 10-K/A
+func isAmendment(fileType FilingType) bool {
+	return strings.HasSuffix(string(fileType), "/A")
+}
+
+// amendmentType gets the type of the amendments of a filing type
+func amendmentType(fileType FilingType) FilingType {
+	return fileType + "/A"
+}
+
+// originalType gets the type of the filings amended by an amendment
+func originalType(fileType FilingType) FilingType {
+	return FilingType(strings.TrimSuffix(string(fileType), "/A"))
+}
+
+// withAmendments adds the types of the amendments of the filing types to
+// the filing types
+func withAmendments(fileTypes []FilingType) []FilingType {
+	requested := make(map[FilingType]bool)
+	for _, t := range fileTypes {
+		requested[t] = true
+	}
+	var types []FilingType
+	for _, t := range fileTypes {
+		types = append(types, t)
+		if a := amendmentType(t); !isAmendment(t) && !requested[a] {
+			requested[a] = true
+			types = append(types, a)
+		}
+	}
+	return types
+}
+
 type company struct {
 	sync.Mutex
-	Company     string `json:"Company"`
-	cik         string
-	FilingLinks map[FilingType]map[string]string  `json:"-"`
-	Reports     map[FilingType]map[string]*filing `json:"Financial Reports"`
+	Company string `json:"Company"`
+	// CentralIndexKey is the CIK of the company. It is saved with the folder
+	// so that the CIK is not looked up again when the folder is read back.
+	CentralIndexKey string `json:"CIK,omitempty"`
+	client          *client
+	FilingLinks     map[FilingType]map[string]filingLink `json:"-"`
+	Reports         map[FilingType]map[string]*filing    `json:"Financial Reports"`
+	// fetching are the filings being fetched by their type and date
+	fetching map[string]*filingCall
 }
 
 func (c *company) String() string {
@@ -26,11 +93,11 @@ func (c *company) String() string {
 	return string(data)
 }
 
-func newCompany(ticker string) *company {
+func newCompany(c *client, ticker string) *company {
 	return &company{
 		Company:     ticker,
-		cik:         getCompanyCIK(ticker),
-		FilingLinks: make(map[FilingType]map[string]string),
+		client:      c,
+		FilingLinks: make(map[FilingType]map[string]filingLink),
 		Reports:     make(map[FilingType]map[string]*filing),
 	}
 }
@@ -40,32 +107,165 @@ func (c *company) Ticker() string {
 }
 
 func (c *company) Filing(fileType FilingType, ts time.Time) (Filing, error) {
-	file, ok := c.getReport(fileType, ts)
-	if !ok {
-		link, ok1 := c.getFilingLink(fileType, ts)
-		if !ok1 {
-			log.Println(c.AvailableFilings(fileType))
-			return nil, errors.New("No filing available for given date " + getDateString(ts))
+	return c.FilingContext(context.Background(), fileType, ts)
+}
+
+func (c *company) FilingContext(ctx context.Context, fileType FilingType, ts time.Time) (Filing, error) {
+	file, err := c.filing(ctx, fileType, ts)
+	if err != nil {
+		return nil, err
+	}
+	if !c.client.amended || isAmendment(fileType) {
+		return file, nil
+	}
+	amendments := c.amendments(fileType, ts)
+	if len(amendments) == 0 {
+		return file, nil
+	}
+
+	// The latest amended view is the filing with every amendment merged
+	// into it in the order of filing
+	view := &filing{
+		Company: file.Company,
+		Date:    file.Date,
+		FinData: newFinancialReport(fileType),
+	}
+	mergeFinancialReport(view.FinData, file.FinData)
+	for _, filed := range amendments {
+		amendment, err := c.filing(ctx, amendmentType(fileType), filed)
+		// Amendments without financial statements have nothing to merge
+		if errors.Is(err, errNoFinancialData) {
+			continue
 		}
-		file = new(filing)
-		var err error
-		file.FinData, err = getFinancialData(link, fileType)
-		if file.FinData != nil {
-			file.Date = Timestamp(ts)
-			file.Company = c.Ticker()
-			c.AddReport(file)
-			if err != nil {
-				log.Println(file.Company + "-Filed on: " + getDateString(ts) + ":" + err.Error())
-			}
-			return file, nil
+		if err != nil {
+			return nil, fmt.Errorf("Amendment filed on %s: %w", getDateString(filed), err)
+		}
+		mergeFinancialReport(view.FinData, amendment.FinData)
+		view.Merged = append(view.Merged, amendment.Date)
+	}
+	return view, nil
+}
+
+// filing gets a filing from the folder and fetches the filing if it is
+// not in the folder yet. A filing is fetched once at a time: the callers
+// asking for a filing that is being fetched wait for it.
+func (c *company) filing(ctx context.Context, fileType FilingType, ts time.Time) (*filing, error) {
+	key := string(fileType) + "/" + getDateString(ts)
+	c.Lock()
+	if file, ok := c.Reports[fileType][getDateString(ts)]; ok {
+		c.Unlock()
+		return file, nil
+	}
+	if call, ok := c.fetching[key]; ok {
+		c.Unlock()
+		select {
+		case <-call.done:
+			return call.file, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
+	}
+	if c.fetching == nil {
+		c.fetching = make(map[string]*filingCall)
+	}
+	call := &filingCall{done: make(chan struct{})}
+	c.fetching[key] = call
+	c.Unlock()
+
+	call.file, call.err = c.fetchFiling(ctx, fileType, ts)
+	c.Lock()
+	delete(c.fetching, key)
+	c.Unlock()
+	close(call.done)
+	return call.file, call.err
+}
+
+// filingCall is a filing being fetched
+type filingCall struct {
+	done chan struct{}
+	file *filing
+	err  error
+}
+
+// fetchFiling fetches a filing and adds it to the folder
+func (c *company) fetchFiling(ctx context.Context, fileType FilingType, ts time.Time) (*filing, error) {
+	link, ok := c.getFilingLink(fileType, ts)
+	if !ok {
+		log.Println(c.AvailableFilings(fileType))
+		return nil, errors.New("No filing available for given date " + getDateString(ts))
+	}
+	file := new(filing)
+	var err error
+	tags := c.client.mappings.metricTags(c.Ticker())
+	file.FinData, err = c.client.getFinancialData(ctx, link, fileType, tags)
+	if file.FinData == nil {
 		return nil, err
 	}
+	file.Date = Timestamp(ts)
+	file.Company = c.Ticker()
+	if original, ok := c.original(fileType, link); ok {
+		file.Original = &original
+	}
+	// The filing is complete before it is shared through the folder
+	if fileType == FilingType10Q {
+		c.deriveQuarter(ctx, file)
+	}
+	c.AddReport(file)
+	if err != nil {
+		log.Println(file.Company + "-Filed on: " + getDateString(ts) + ":" + err.Error())
+	}
 	return file, nil
 }
 
+// deriveQuarter derives the data of a quarterly filing that is reported
+// only for the year to date. The year to date of the previous quarter is
+// taken from the quarterly filing filed before the filing, which is
+// fetched if it is not in the folder. It is called before the filing is
+// added to the folder as the filings of the folder are only read.
+func (c *company) deriveQuarter(ctx context.Context, file *filing) {
+	if file.FinData == nil || !file.FinData.quarterMissing() {
+		return
+	}
+	prev, ok := c.filedBefore(FilingType10Q, file.FiledOn())
+	if !ok {
+		return
+	}
+	prevFile, err := c.filing(ctx, FilingType10Q, prev)
+	if err != nil || prevFile.FinData == nil {
+		return
+	}
+	file.FinData.deriveQuarter(prevFile.FinData)
+}
+
+// filedBefore gets the date of the latest filing of a type filed before
+// the given date, whether it is in the folder or still to be fetched
+func (c *company) filedBefore(fileType FilingType, ts time.Time) (time.Time, bool) {
+	c.Lock()
+	defer c.Unlock()
+	var latest string
+	before := getDateString(ts)
+	for filed := range c.FilingLinks[fileType] {
+		if filed < before && filed > latest {
+			latest = filed
+		}
+	}
+	for filed := range c.Reports[fileType] {
+		if filed < before && filed > latest {
+			latest = filed
+		}
+	}
+	if latest == "" {
+		return time.Time{}, false
+	}
+	return time.Time(getDate(latest)), true
+}
+
 // Get multiple filings in parallel
 func (c *company) Filings(fileType FilingType, ts ...time.Time) ([]Filing, error) {
+	return c.FilingsContext(context.Background(), fileType, ts...)
+}
+
+func (c *company) FilingsContext(ctx context.Context, fileType FilingType, ts ...time.Time) ([]Filing, error) {
 	var wg sync.WaitGroup
 	var ret []Filing
 	var retErrors []error
@@ -74,12 +274,12 @@ func (c *company) Filings(fileType FilingType, ts ...time.Time) ([]Filing, error
 		wg.Add(1)
 		go func(filed time.Time) {
 			defer wg.Done()
-			file, err := c.Filing(fileType, filed)
+			file, err := c.FilingContext(ctx, fileType, filed)
 			m.Lock()
 			if err == nil {
 				ret = append(ret, file)
 			} else {
-				err = errors.New(getDateString(filed) + ":" + err.Error())
+				err = fmt.Errorf("%s:%w", getDateString(filed), err)
 				retErrors = append(retErrors, err)
 			}
 			m.Unlock()
@@ -87,11 +287,7 @@ func (c *company) Filings(fileType FilingType, ts ...time.Time) ([]Filing, error
 	}
 	wg.Wait()
 	if len(ts) != len(ret) && len(retErrors) > 0 {
-		errString := "Failed to retrieve some filings: \n"
-		for _, e := range retErrors {
-			errString = errString + e.Error() + "\n"
-		}
-		return ret, errors.New(errString)
+		return ret, &filingsError{errs: retErrors}
 	}
 	return ret, nil
 }
@@ -132,17 +328,61 @@ func (c *company) AvailableFilings(filingType FilingType) []time.Time {
 }
 
 func (c *company) CIK() string {
-	return c.cik
+	return c.CentralIndexKey
 }
 
-func (c *company) getFilingLink(fileType FilingType, ts time.Time) (string, bool) {
+func (c *company) getFilingLink(fileType FilingType, ts time.Time) (filingLink, bool) {
 	c.Lock()
 	defer c.Unlock()
 	link, ok := c.FilingLinks[fileType][getDateString(ts)]
 	return link, ok
 }
 
-func (c *company) addFilingLinks(fileType FilingType, files map[string]string) {
+// original finds the filing amended by an amendment. An amendment amends
+// the filing for the same period of report. When the period is not known
+// the amendment amends the latest filing filed before it.
+func (c *company) original(fileType FilingType, link filingLink) (Timestamp, bool) {
+	if !isAmendment(fileType) {
+		return Timestamp{}, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	var latest string
+	for filed, l := range c.FilingLinks[originalType(fileType)] {
+		if link.ReportDate != "" && l.ReportDate != "" {
+			if l.ReportDate == link.ReportDate {
+				return getDate(filed), true
+			}
+			continue
+		}
+		if filed <= link.FilingDate && filed > latest {
+			latest = filed
+		}
+	}
+	if latest == "" {
+		return Timestamp{}, false
+	}
+	return getDate(latest), true
+}
+
+// amendments gets the dates of filing of the amendments of a filing, the
+// earliest first
+func (c *company) amendments(fileType FilingType, ts time.Time) []time.Time {
+	var d []time.Time
+	for _, filed := range c.AvailableFilings(amendmentType(fileType)) {
+		link, _ := c.getFilingLink(amendmentType(fileType), filed)
+		if original, ok := c.original(amendmentType(fileType), link); ok &&
+			getDateString(time.Time(original)) == getDateString(ts) {
+			d = append(d, filed)
+		}
+	}
+	sort.Slice(d, func(i, j int) bool {
+		return d[i].Before(d[j])
+	})
+	return d
+}
+
+func (c *company) addFilingLinks(fileType FilingType, files map[string]filingLink) {
 	c.Lock()
 	defer c.Unlock()
 	c.FilingLinks[fileType] = files
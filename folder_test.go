@@ -0,0 +1,958 @@
+package edgar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palafrank/edgar/edgartest"
+)
+
+func sampleFetcher(s *edgartest.Server, opts ...Option) FilingFetcher {
+	opts = append([]Option{
+		WithBaseURL(s.URL),
+		WithUserAgent(testUserAgent),
+		WithRateLimit(0),
+		WithBackoff(time.Millisecond, 5*time.Millisecond),
+	}, opts...)
+	return NewFilingFetcher(opts...)
+}
+
+func TestSampleServerFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	c, err := sampleFetcher(s).CompanyFolder("AAPL", FilingType10Q, FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CIK() != "0000320193" {
+		t.Error("Incorrect CIK for the folder ", c.CIK())
+	}
+	dates := c.AvailableFilings(FilingType10Q)
+	if len(dates) != 1 || getDateString(dates[0]) != "2018-08-01" {
+		t.Fatal("Incorrect filings available ", dates)
+	}
+
+	fs, err := c.Filing(FilingType10Q, dates[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.ShareCount(); val != 4829926000 {
+		t.Error("Incorrect share count ", val)
+	}
+
+	filings, err := c.Filings(FilingType10K, c.AvailableFilings(FilingType10K)...)
+	if err != nil || len(filings) != 1 {
+		t.Fatal("Failed to get the 10-K filings ", err)
+	}
+	if val, _ := filings[0].ShareCount(); val != 5575331000 {
+		t.Error("Incorrect 10-K share count ", val)
+	}
+
+	// Parsed filings are kept in the folder
+	before := s.Requests("/Archives/")
+	c.Filing(FilingType10Q, dates[0])
+	if s.Requests("/Archives/") != before {
+		t.Error("Filing was fetched again from EDGAR")
+	}
+}
+
+func TestFilingSummaryFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	c, err := sampleFetcher(s).CompanyFolder("AAPL", FilingType10Q, FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Filing(FilingType10Q, c.AvailableFilings(FilingType10Q)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests("FilingSummary.xml") != 1 || s.Requests("/cgi-bin/viewer") != 0 {
+		t.Error("Reports of the 10-Q were not identified by the filing summary")
+	}
+
+	// The 10-K has no filing summary
+	if _, err := c.Filing(FilingType10K, c.AvailableFilings(FilingType10K)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests("FilingSummary.xml") != 2 || s.Requests("/cgi-bin/viewer") != 1 {
+		t.Error("Reports of the 10-K were not identified by the viewer")
+	}
+}
+
+func TestXBRLParserFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	c, err := sampleFetcher(s, WithParser(ParseXBRL)).CompanyFolder("AAPL", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := c.Filing(FilingType10Q, c.AvailableFilings(FilingType10Q)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.ShareCount(); val != 4829926000 {
+		t.Error("Incorrect share count ", val)
+	}
+	if val, _ := fs.GrossMargin(); val != 20421000000 {
+		t.Error("Incorrect gross margin ", val)
+	}
+	if s.Requests("/R") != 0 || s.Requests("sample_10Q.xml") != 1 {
+		t.Error("Filing was not parsed from the XBRL instance")
+	}
+}
+
+func TestDerivedQuarterFolder(t *testing.T) {
+	s := edgartest.NewServer()
+	defer s.Close()
+	comp := edgartest.Company{Ticker: "TEST", CIK: "0000000001"}
+	for i, filed := range []string{"2018-02-02", "2018-05-02", "2018-08-01"} {
+		comp.Filings = append(comp.Filings, edgartest.Filing{
+			Type:      "10-Q",
+			Filed:     filed,
+			Accession: fmt.Sprintf("0000000001-18-00000%d", i+1),
+			Instance:  "samples/sample_10Q.xml",
+		})
+	}
+	s.AddCompany(comp)
+
+	c, err := sampleFetcher(s, WithParser(ParseXBRL)).CompanyFolder("TEST", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The quarters need the cash flows of the quarter filed before them and
+	// every filing is fetched once while they are fetched together
+	fs, err := c.Filings(FilingType10Q, c.AvailableFilings(FilingType10Q)...)
+	if err != nil || len(fs) != 3 {
+		t.Fatal("Incorrect filings ", len(fs), err)
+	}
+	for i := 1; i <= 3; i++ {
+		if n := s.Requests(fmt.Sprintf("/00000000011800000%d/sample_10Q.xml", i)); n != 1 {
+			t.Error("Incorrect number of fetches of the filing ", i, n)
+		}
+	}
+}
+
+func TestInlineXBRLParserFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	c, err := sampleFetcher(s, WithParser(ParseInlineXBRL)).CompanyFolder("AAPL", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := c.Filing(FilingType10Q, c.AvailableFilings(FilingType10Q)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.ShareCount(); val != 4829926000 {
+		t.Error("Incorrect share count ", val)
+	}
+	if s.Requests("/R") != 0 || s.Requests("sample_10Q_ixbrl.htm") != 1 {
+		t.Error("Filing was not parsed from the primary document")
+	}
+}
+
+func TestSampleServerFailures(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	c, err := sampleFetcher(s).CompanyFolder("AAPL", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filed := c.AvailableFilings(FilingType10Q)[0]
+
+	s.Fail("R5.htm", edgartest.FailServerError)
+	_, err = c.Filing(FilingType10Q, filed)
+	if _, ok := err.(*ServerError); !ok {
+		t.Error("Expected a server error ", err)
+	}
+
+	s.Fail("R5.htm", edgartest.FailTruncated)
+	if _, err = c.Filing(FilingType10Q, filed); err == nil {
+		t.Error("Expected an error for a truncated page")
+	}
+
+	s.Fail("R5.htm", edgartest.FailTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.FilingContext(ctx, FilingType10Q, filed)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected the deadline to be exceeded ", err)
+	}
+
+	s.Fail("R5.htm", edgartest.FailNone)
+	if _, err = c.Filing(FilingType10Q, filed); err != nil {
+		t.Error("Failed to get the filing once EDGAR recovered ", err)
+	}
+
+	if _, err := sampleFetcher(s).CompanyFolder("MSFT"); err == nil {
+		t.Error("Expected an error for an unknown company")
+	}
+}
+
+func TestFilingDiscoveryPages(t *testing.T) {
+	s := edgartest.NewServer()
+	defer s.Close()
+	comp := edgartest.Company{Ticker: "TEST", CIK: "0000000001"}
+	for year := 2005; year <= 2018; year++ {
+		for _, month := range []string{"02", "05", "08"} {
+			comp.Filings = append(comp.Filings, edgartest.Filing{
+				Type:      "10-Q",
+				Filed:     fmt.Sprintf("%d-%s-01", year, month),
+				Accession: fmt.Sprintf("0000000001-%02d-0000%s", year%100, month),
+			})
+		}
+	}
+	// A filing without interactive data is listed in the first page
+	comp.Filings = append(comp.Filings, edgartest.Filing{
+		Type:      "10-Q",
+		Filed:     "2018-08-15",
+		Accession: "0000000001-18-000009",
+		NoXBRL:    true,
+	})
+	s.AddCompany(comp)
+
+	f := sampleFetcher(s, WithEarliestYear(2010)).(*fetcher)
+	f.client.pageSize = 10
+	c, err := f.CompanyFolder("TEST", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := c.AvailableFilings(FilingType10Q)
+	if len(dates) != 27 {
+		t.Error("Incorrect number of filings discovered ", len(dates))
+	}
+	if oldest := dates[len(dates)-1]; getDateString(oldest) != "2010-02-01" {
+		t.Error("Incorrect oldest filing discovered ", oldest)
+	}
+	// 28 filings since 2010 need three pages and the third page goes past 2010
+	if pages := s.Requests("type=10-Q"); pages != 3 {
+		t.Error("Incorrect number of pages walked ", pages)
+	}
+}
+
+func TestSubmissionsDiscovery(t *testing.T) {
+	s := edgartest.NewServer()
+	defer s.Close()
+	s.RecentFilings = 10
+	comp := edgartest.Company{Ticker: "TEST", CIK: "0000000001"}
+	for year := 2005; year <= 2018; year++ {
+		for _, month := range []string{"02", "05", "08"} {
+			comp.Filings = append(comp.Filings, edgartest.Filing{
+				Type:            "10-Q",
+				Filed:           fmt.Sprintf("%d-%s-01", year, month),
+				Accession:       fmt.Sprintf("0000000001-%02d-0000%s", year%100, month),
+				ReportDate:      fmt.Sprintf("%d-%s-30", year, month),
+				PrimaryDocument: "test-10q.htm",
+			})
+		}
+	}
+	comp.Filings = append(comp.Filings, edgartest.Filing{
+		Type:      "10-K",
+		Filed:     "2018-02-20",
+		Accession: "0000000001-18-000001",
+	})
+	s.AddCompany(comp)
+
+	f := sampleFetcher(s, WithEarliestYear(2010), WithDataURL(s.URL), WithDiscovery(DiscoverSubmissions))
+	folder, err := f.CompanyFolder("TEST", FilingType10Q, FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dates := folder.AvailableFilings(FilingType10Q); len(dates) != 27 {
+		t.Error("Incorrect number of filings discovered ", len(dates))
+	}
+	if dates := folder.AvailableFilings(FilingType10K); len(dates) != 1 {
+		t.Error("Incorrect number of 10-K filings discovered ", len(dates))
+	}
+	// The history files with filings only before 2010 are not read
+	if pages := s.Requests("/submissions/"); pages != 3 {
+		t.Error("Incorrect number of submission files read ", pages)
+	}
+
+	link, ok := folder.(*company).getFilingLink(FilingType10Q, time.Time(getDate("2012-05-01")))
+	if !ok {
+		t.Fatal("Filing discovered in a history file is missing")
+	}
+	if link.Accession != "0000000001-12-000005" || link.ReportDate != "2012-05-30" ||
+		link.PrimaryDocument != "test-10q.htm" || !link.IsXBRL || link.Form != "10-Q" {
+		t.Error("Incorrect filing information ", link)
+	}
+	if link.Link != "/cgi-bin/viewer?action=view&cik=1&accession_number=0000000001-12-000005&xbrl_type=v" {
+		t.Error("Incorrect filing link ", link.Link)
+	}
+}
+
+func TestSubmissionsPrimaryDocument(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+	s.AddCompany(edgartest.Company{
+		Ticker: "TEST",
+		CIK:    "0000000001",
+		Filings: []edgartest.Filing{{
+			Type:      "10-Q",
+			Filed:     "2018-05-01",
+			Accession: "0000000001-18-000001",
+			NoXBRL:    true,
+		}},
+	})
+
+	f := sampleFetcher(s, WithDataURL(s.URL), WithDiscovery(DiscoverSubmissions), WithParser(ParseInlineXBRL))
+	c, err := f.CompanyFolder("AAPL", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := c.Filing(FilingType10Q, time.Time(getDate("2018-08-01")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect revenue ", val)
+	}
+	if s.Requests("index.json") != 0 || s.Requests("sample_10Q_ixbrl.htm") != 1 {
+		t.Error("Primary document of the submissions was not used")
+	}
+
+	c, err = f.CompanyFolder("TEST", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Filing(FilingType10Q, time.Time(getDate("2018-05-01"))); err == nil {
+		t.Error("Expected an error for a filing without XBRL")
+	}
+	if s.Requests("/Archives/edgar/data/1/") != 0 || s.Requests("cik=1&") != 0 {
+		t.Error("Filing without XBRL was fetched")
+	}
+}
+
+var sampleTickers = `{"0":{"cik_str":320193,"ticker":"AAPL","title":"Apple Inc."},
+"1":{"cik_str":789019,"ticker":"MSFT","title":"MICROSOFT CORP"},
+"2":{"cik_str":1067983,"ticker":"BRK-B","title":"BERKSHIRE HATHAWAY INC"},
+"3":{"cik_str":1067983,"ticker":"BRK-A","title":"BERKSHIRE HATHAWAY INC"}}`
+
+var sampleTickersExchange = `{"fields":["cik","name","ticker","exchange"],
+"data":[[320193,"Apple Inc.","AAPL","Nasdaq"],[789019,"MICROSOFT CORP","MSFT","Nasdaq"],
+[1018724,"AMAZON COM INC","AMZN","Nasdaq"]]}`
+
+func TestTickerResolver(t *testing.T) {
+	r, err := NewTickerResolver(strings.NewReader(sampleTickers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := r.LookupTicker("brk-a"); err != nil || info.CIK != "0001067983" {
+		t.Error("Incorrect lookup of ticker ", info, err)
+	}
+	if info, err := r.LookupCIK("1067983"); err != nil || info.Ticker != "BRK-B" || info.Name != "BERKSHIRE HATHAWAY INC" {
+		t.Error("Incorrect lookup of CIK ", info, err)
+	}
+	if infos, err := r.LookupName("apple inc."); err != nil || len(infos) != 1 || infos[0].Ticker != "AAPL" {
+		t.Error("Incorrect lookup of name ", infos, err)
+	}
+	if infos, err := r.LookupName("HATHAWAY"); err != nil || len(infos) != 1 || infos[0].CIK != "0001067983" {
+		t.Error("Incorrect lookup of partial name ", infos, err)
+	}
+	if _, err := r.LookupTicker("GOOG"); err == nil {
+		t.Error("Unknown ticker found")
+	}
+
+	r, err = NewTickerResolver(strings.NewReader(sampleTickersExchange))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := r.LookupCIK("0001018724"); err != nil || info.Ticker != "AMZN" || info.Exchange != "Nasdaq" {
+		t.Error("Incorrect lookup with exchange ", info, err)
+	}
+}
+
+func TestResolverFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+	s.AddCompany(edgartest.Company{Ticker: "GE", CIK: "0000040545"})
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	f := sampleFetcher(s, WithCIKResolver(r))
+	c, err := f.CompanyFolder("AAPL", FilingType10Q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CIK() != "0000320193" {
+		t.Error("Incorrect CIK from the resolver ", c.CIK())
+	}
+	if s.Requests("output=xml") != 0 {
+		t.Error("CIK was looked up on EDGAR")
+	}
+
+	// Tickers unknown to the resolver are looked up on EDGAR
+	c, err = f.CompanyFolder("GE")
+	if err != nil || c.CIK() != "0000040545" {
+		t.Error("CIK lookup did not fall back to EDGAR ", err)
+	}
+	if s.Requests("output=xml") != 1 {
+		t.Error("CIK was not looked up on EDGAR")
+	}
+
+	// The CIK is saved with the folder and not looked up again
+	if !strings.Contains(c.String(), `"CIK": "0000040545"`) {
+		t.Error("CIK was not saved with the folder ", c.String())
+	}
+	c, err = sampleFetcher(s).CreateFolder(strings.NewReader(c.String()))
+	if err != nil || c.CIK() != "0000040545" {
+		t.Error("Incorrect CIK of the folder read back ", err)
+	}
+	if s.Requests("output=xml") != 1 {
+		t.Error("CIK of the folder read back was looked up on EDGAR")
+	}
+}
+
+func TestForeignIssuerFolder(t *testing.T) {
+	s := edgartest.NewServer()
+	defer s.Close()
+	s.AddCompany(edgartest.Company{
+		Ticker: "SAP",
+		CIK:    "0001000184",
+		Filings: []edgartest.Filing{
+			{
+				Type:      "20-F",
+				Filed:     "2019-02-28",
+				Accession: "0001000184-19-000010",
+				Viewer:    "samples/sample_20F.html",
+				Reports: map[int]string{
+					2: "samples/sample_20F_ops.html",
+					4: "samples/sample_20F_bs.html",
+				},
+			},
+		},
+	})
+
+	c, err := sampleFetcher(s).CompanyFolder("SAP", FilingType20F)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := c.AvailableFilings(FilingType20F)
+	if len(dates) != 1 {
+		t.Fatal("Incorrect 20-F filings available ", dates)
+	}
+	fs, err := c.Filing(FilingType20F, dates[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := fs.Type(); typ != FilingType20F {
+		t.Error("Incorrect filing type ", typ)
+	}
+	if val, _ := fs.Revenue(); val != 24708000000 {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.NetIncome(); val != 4088000000 {
+		t.Error("Incorrect net income ", val)
+	}
+	if val, _ := fs.Assets(); val != 51502000000 {
+		t.Error("Incorrect assets ", val)
+	}
+}
+
+func TestAmendedFilings(t *testing.T) {
+	// The viewer of the amendment lists the reports of the amendment
+	b, err := ioutil.ReadFile("samples/sample_10K.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer := filepath.Join(t.TempDir(), "amendment.html")
+	b = bytes.Replace(b, []byte("000119312515356351"), []byte("000119312516000001"), -1)
+	if err := ioutil.WriteFile(viewer, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := edgartest.NewServer()
+	defer s.Close()
+	s.AddCompany(edgartest.Company{
+		Ticker: "AAPL",
+		CIK:    "0000320193",
+		Filings: []edgartest.Filing{
+			{
+				Type:      "10-K",
+				Filed:     "2015-10-28",
+				Accession: "0001193125-15-356351",
+				Viewer:    "samples/sample_10K.html",
+				Reports: map[int]string{
+					1: "samples/sample_10K_entity.html",
+					2: "samples/sample_10K_ops.html",
+					5: "samples/sample_10K_bs.html",
+					8: "samples/sample_10K_cf.html",
+				},
+			},
+			{
+				Type:      "10-K/A",
+				Filed:     "2016-01-15",
+				Accession: "0001193125-16-000001",
+				Viewer:    viewer,
+				Reports: map[int]string{
+					2: "samples/sample_ops.html",
+				},
+			},
+			{
+				// An amendment of Part III without financial statements
+				Type:      "10-K/A",
+				Filed:     "2016-02-01",
+				Accession: "0001193125-16-000002",
+				NoXBRL:    true,
+			},
+		},
+	})
+
+	c, err := sampleFetcher(s, WithAmendedFilings(true)).CompanyFolder("AAPL", FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amended := c.AvailableFilings(FilingType10KA)
+	if len(amended) != 1 || len(c.AvailableFilings(FilingType10K)) != 1 {
+		t.Fatal("Amendments not collected with the filings ", amended)
+	}
+
+	fa, err := c.Filing(FilingType10KA, amended[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orig, err := fa.Amends(); !fa.IsAmendment() || err != nil || getDateString(orig) != "2015-10-28" {
+		t.Error("Incorrect original of the amendment ", orig, err)
+	}
+
+	fs, err := c.Filing(FilingType10K, time.Time(getDate("2015-10-28")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.IsAmendment() {
+		t.Error("Amended view reported as an amendment")
+	}
+	if d := fs.Amendments(); len(d) != 1 || getDateString(d[0]) != "2016-01-15" {
+		t.Error("Incorrect amendments merged ", d)
+	}
+	// Revenue is restated by the amendment and the rest is from the original
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect amended revenue ", val)
+	}
+	if val, _ := fs.ShareCount(); val != 5575331000 {
+		t.Error("Incorrect share count ", val)
+	}
+
+	// The amendment without XBRL is discovered through the submissions
+	// but it is left out of the amended view
+	c, err = sampleFetcher(s, WithAmendedFilings(true), WithDataURL(s.URL), WithDiscovery(DiscoverSubmissions)).CompanyFolder("AAPL", FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amended := c.AvailableFilings(FilingType10KA); len(amended) != 2 {
+		t.Fatal("Amendments not discovered ", amended)
+	}
+	fs, err = c.Filing(FilingType10K, time.Time(getDate("2015-10-28")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := fs.Amendments(); len(d) != 1 || getDateString(d[0]) != "2016-01-15" {
+		t.Error("Incorrect amendments merged ", d)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect amended revenue ", val)
+	}
+}
+
+func TestCompanyFactsFolder(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	f := sampleFetcher(s, WithCIKResolver(r), WithEarliestYear(2017))
+	c, err := f.LoadCompanyFactsFolder("samples/sample_companyfacts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Ticker() != "AAPL" || c.CIK() != "0000320193" {
+		t.Error("Incorrect company of the folder ", c.Ticker(), c.CIK())
+	}
+	// Filings of other types, like the 8-K, are not collected
+	q, k := c.AvailableFilings(FilingType10Q), c.AvailableFilings(FilingType10K)
+	if len(q) != 5 || getDateString(q[0]) != "2018-08-01" || len(k) != 1 || getDateString(k[0]) != "2017-11-03" {
+		t.Fatal("Incorrect filings available ", q, k)
+	}
+
+	// The quarters are derived when the folder is made. Looking up the
+	// filings only reads them.
+	if file := c.(*company).Reports[FilingType10Q]["2018-08-01"]; !file.FinData.isDerived(finDataCapEx) {
+		t.Error("Expected the quarter to be derived with the folder")
+	}
+	fs, err := c.Filing(FilingType10Q, q[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 53265000000 {
+		t.Error("Incorrect quarterly revenue ", val)
+	}
+	if val, _ := fs.Assets(); val != 349197000000 {
+		t.Error("Incorrect assets ", val)
+	}
+	// The cash flows of the quarter are derived from the year to date of
+	// the previous quarter
+	if val, _ := fs.CapitalExpenditure(); val != -3267000000 || !fs.IsDerived(MetricCapitalExpenditure) {
+		t.Error("Incorrect capital expenditure ", val)
+	}
+	if val, _ := fs.OperatingCashFlow(); val != 14488000000 {
+		t.Error("Incorrect operating cash flow ", val)
+	}
+	if fs.IsDerived(MetricRevenue) {
+		t.Error("Revenue of the quarter is reported")
+	}
+	if val, _ := fs.ShareCount(); val != 4829926000 {
+		t.Error("Incorrect share count ", val)
+	}
+	if end, _ := fs.PeriodEnd(); getDateString(end) != "2018-06-30" {
+		t.Error("Incorrect period end ", end)
+	}
+	if year, _ := fs.FiscalYear(); year != 2018 {
+		t.Error("Incorrect fiscal year ", year)
+	}
+	if period, _ := fs.FiscalPeriod(); period != "Q3" {
+		t.Error("Incorrect fiscal period ", period)
+	}
+
+	// Without a previous quarter the quarter is not known
+	fs, err = c.Filing(FilingType10Q, q[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 61137000000 {
+		t.Error("Incorrect quarterly revenue ", val)
+	}
+	if _, err := fs.CapitalExpenditure(); err == nil {
+		t.Error("Expected an error for the capital expenditure of the quarter")
+	}
+	if capex := fs.PeriodValues(MetricCapitalExpenditure); len(capex) != 1 || capex[0].Months != 6 {
+		t.Error("Incorrect capital expenditure for the year to date ", capex)
+	}
+
+	fs, err = c.Filing(FilingType10K, k[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 229234000000 {
+		t.Error("Incorrect annual revenue ", val)
+	}
+	if val, _ := fs.DividendPerShare(); val != 2.40 {
+		t.Error("Incorrect dividend per share ", val)
+	}
+	if s.Requests("/") != 0 {
+		t.Error("Filings were fetched from EDGAR")
+	}
+
+	// Without a resolver the folder is named after the company
+	c, err = sampleFetcher(s, WithEarliestYear(2018)).LoadCompanyFactsFolder("samples/sample_companyfacts.json", FilingType10K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Ticker() != "Apple Inc." || len(c.AvailableFilings(FilingType10K)) != 0 {
+		t.Error("Incorrect folder without a resolver ", c.Ticker(), c.AvailableFilings(FilingType10K))
+	}
+	if _, err := sampleFetcher(s).CompanyFactsFolder(strings.NewReader(`{"cik":320193}`)); err == nil {
+		t.Error("Expected an error for a document without facts")
+	}
+}
+
+func TestFourthQuarter(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	c, err := sampleFetcher(s, WithCIKResolver(r), WithEarliestYear(2017)).LoadCompanyFactsFolder("samples/sample_companyfacts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := c.AvailableFilings(FilingType10K)
+	fs, err := c.FourthQuarter(k[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period, _ := fs.FiscalPeriod(); period != "Q4" {
+		t.Error("Incorrect fiscal period ", period)
+	}
+	if end, _ := fs.PeriodEnd(); getDateString(end) != "2017-09-30" {
+		t.Error("Incorrect period end ", end)
+	}
+	// The flow data is the year less the first three quarters
+	if val, _ := fs.Revenue(); val != 52579000000 || !fs.IsDerived(MetricRevenue) {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.NetIncome(); val != 10714000000 {
+		t.Error("Incorrect net income ", val)
+	}
+	if val, _ := fs.OperatingCashFlow(); val != 15600000000 || !fs.IsDerived(MetricOperatingCashFlow) {
+		t.Error("Incorrect operating cash flow ", val)
+	}
+	if val, _ := fs.CapitalExpenditure(); val != -3544000000 {
+		t.Error("Incorrect capital expenditure ", val)
+	}
+	if val, _ := fs.Dividend(); val != 3189000000 {
+		t.Error("Incorrect dividend ", val)
+	}
+	if val, _ := fs.DividendPerShare(); val != 0.63 {
+		t.Error("Incorrect dividend per share ", val)
+	}
+	// The balance sheet is the balance sheet of the 10-K
+	if val, _ := fs.Assets(); val != 375319000000 || fs.IsDerived(MetricAssets) {
+		t.Error("Incorrect assets ", val)
+	}
+	if revenue := fs.PeriodValues(MetricRevenue); len(revenue) != 1 || revenue[0].Months != 3 {
+		t.Error("Incorrect revenue periods ", revenue)
+	}
+	// The share counts are only kept when the 10-K reports the quarter
+	if _, err := fs.WAShares(); err == nil {
+		t.Error("Expected the share count of the quarter to be missing")
+	}
+	annual, _ := c.Filing(FilingType10K, k[0])
+	fr := annual.(*filing).FinData
+	fr.addPeriod(finDataWAShares, fr.periodEnd(), 3, "5,183,000,000", nil, filingDocOps)
+	if fs, err = c.FourthQuarter(k[0]); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.WAShares(); val != 5183000000 || fs.IsDerived(MetricWAShares) {
+		t.Error("Incorrect share count ", val)
+	}
+
+	// The quarters of the fiscal year of the 10-K are needed
+	c, _ = sampleFetcher(s, WithCIKResolver(r), WithEarliestYear(2017)).LoadCompanyFactsFolder("samples/sample_companyfacts.json", FilingType10K)
+	if _, err := c.FourthQuarter(k[0]); err == nil {
+		t.Error("Expected an error without the quarterly filings")
+	}
+	if _, err := c.FourthQuarter(time.Now()); err == nil {
+		t.Error("Expected an error for a missing 10-K")
+	}
+}
+
+func TestTTM(t *testing.T) {
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	c, err := sampleFetcher(s, WithCIKResolver(r), WithEarliestYear(2017)).LoadCompanyFactsFolder("samples/sample_companyfacts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The twelve months to the third quarter of FY2018. The revenue of the
+	// prior year is compared in the 10-Q. The rest is in the 10-Q of the
+	// prior year.
+	fs, err := c.TTM(time.Date(2018, 9, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getDateString(fs.FiledOn()) != "2018-08-01" {
+		t.Error("Incorrect filing of the twelve months ", fs.FiledOn())
+	}
+	if val, _ := fs.Revenue(); val != 255274000000 || !fs.IsDerived(MetricRevenue) {
+		t.Error("Incorrect revenue ", val)
+	}
+	if val, _ := fs.NetIncome(); val != 56120000000 {
+		t.Error("Incorrect net income ", val)
+	}
+	if val, _ := fs.OperatingCashFlow(); val != 73511000000 {
+		t.Error("Incorrect operating cash flow ", val)
+	}
+	if val, _ := fs.CapitalExpenditure(); val != -13816000000 {
+		t.Error("Incorrect capital expenditure ", val)
+	}
+	if val, _ := fs.Assets(); val != 349197000000 || fs.IsDerived(MetricAssets) {
+		t.Error("Incorrect assets ", val)
+	}
+	if period, _ := fs.FiscalPeriod(); period != "TTM" {
+		t.Error("Incorrect fiscal period ", period)
+	}
+
+	// After the 10-K the twelve months are the fiscal year
+	fs, err = c.TTM(time.Date(2017, 12, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := fs.Revenue(); val != 229234000000 || fs.IsDerived(MetricRevenue) {
+		t.Error("Incorrect revenue of the fiscal year ", val)
+	}
+
+	// There is no 10-K before the 10-Q filings of FY2017 in the folder
+	if _, err := c.TTM(time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected an error without a 10-K")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	s := edgartest.NewServer()
+	defer s.Close()
+	s.AddFrame("us-gaap", "RevenueFromContractWithCustomerExcludingAssessedTax", "USD", "CY2019Q1", "samples/sample_frame_revenue_contract.json")
+	s.AddFrame("us-gaap", "Revenues", "USD", "CY2019Q1", "samples/sample_frame_revenues.json")
+	s.AddFrame("us-gaap", "Assets", "USD", "CY2019Q1I", "samples/sample_frame_assets.json")
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	f := sampleFetcher(s, WithCIKResolver(r), WithDataURL(s.URL))
+	values, err := f.Frames(MetricRevenue, "CY2019Q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A company gets the value of the preferred concept it reported
+	if len(values) != 4 {
+		t.Fatal("Incorrect number of values ", values)
+	}
+	aapl := values[1]
+	if aapl.CIK != "0000320193" || aapl.Ticker != "AAPL" || aapl.Value != 58015000001 ||
+		aapl.Concept != "us-gaap:Revenues" ||
+		getDateString(aapl.Start) != "2018-12-30" || getDateString(aapl.End) != "2019-03-30" {
+		t.Error("Incorrect value for AAPL ", aapl)
+	}
+	if values[0].Ticker != "" || values[0].Name != "AAR CORP." {
+		t.Error("Incorrect value for a company unknown to the resolver ", values[0])
+	}
+	if values[3].Ticker != "BRK-B" || values[3].Concept != "us-gaap:Revenues" {
+		t.Error("Incorrect value for BRK ", values[3])
+	}
+	if values[2].Ticker != "MSFT" || values[2].Concept != "us-gaap:RevenueFromContractWithCustomerExcludingAssessedTax" {
+		t.Error("Incorrect value for MSFT ", values[2])
+	}
+	// Every concept of the metrics has a priority
+	for key, fin := range xbrlTags {
+		concept, ok := tagConcept(key)
+		if !ok {
+			continue
+		}
+		found := false
+		for _, c := range metricConcepts(fin) {
+			found = found || c == concept
+		}
+		if !found {
+			t.Error("No priority for the concept ", concept)
+		}
+	}
+
+	// Balance sheet metrics are reported as of the end of the period
+	values, err = f.Frames(MetricAssets, "CY2019Q1")
+	if err != nil || len(values) != 2 || values[0].Value != 341998000000 || !values[0].Start.IsZero() {
+		t.Error("Incorrect assets ", values, err)
+	}
+	if s.Requests("Assets/USD/CY2019Q1I.json") != 1 {
+		t.Error("Frame of the instant was not requested")
+	}
+	if _, err := f.Frames(Metric("EBITDA"), "CY2019Q1"); err == nil {
+		t.Error("Expected an error for an unknown metric")
+	}
+
+	file, _ := os.Open("samples/sample_frame_capex.json")
+	values, err = f.ReadFrame(file)
+	file.Close()
+	if err != nil || len(values) != 1 || values[0].Metric != MetricCapitalExpenditure || values[0].Value != -2363000000 {
+		t.Error("Incorrect frame read ", values, err)
+	}
+}
+
+func TestMetricMappings(t *testing.T) {
+	m, err := NewMetricMappings(strings.NewReader(`
+metrics:
+  - name: Cash Dividends
+tags:
+  us-gaap:PaymentsOfDividends: Cash Dividends
+companies:
+  MSFT:
+    defref_us-gaap_SalesRevenueNet: Cash Dividends
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := edgartest.NewSampleServer("samples")
+	defer s.Close()
+
+	r, _ := NewTickerResolver(strings.NewReader(sampleTickers))
+	f := sampleFetcher(s, WithCIKResolver(r), WithEarliestYear(2017), WithMetricMappings(m))
+	c, err := f.LoadCompanyFactsFolder("samples/sample_companyfacts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := c.Filing(FilingType10K, c.AvailableFilings(FilingType10K)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tag is collected as the custom metric instead of the dividend
+	if val, err := fs.Value("Cash Dividends"); err != nil || val != 12769000000 {
+		t.Error("Incorrect custom metric ", val, err)
+	}
+	if _, err := fs.Dividend(); err == nil {
+		t.Error("Expected the dividend to be collected as the custom metric")
+	}
+	if periods := fs.PeriodValues("Cash Dividends"); len(periods) != 2 || periods[1].Value != 12150000000 {
+		t.Error("Incorrect periods of the custom metric ", periods)
+	}
+	// The mappings of other companies do not apply
+	if val, err := fs.Value(MetricRevenue); err != nil || val != 229234000000 {
+		t.Error("Incorrect revenue ", val, err)
+	}
+	collected := fs.CollectedData()
+	if collected[len(collected)-1] != "Cash Dividends" {
+		t.Error("Expected the custom metric to be collected ", collected)
+	}
+
+	// The quarter, the fourth quarter and the trailing year of custom
+	// metrics are derived like the metrics of the package
+	quarter := time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC)
+	if fs, err := c.Filing(FilingType10Q, quarter); err != nil {
+		t.Error(err)
+	} else if val, err := fs.Value("Cash Dividends"); err != nil || val != 3653000000 || !fs.IsDerived("Cash Dividends") {
+		t.Error("Incorrect custom metric of the quarter ", val, err)
+	}
+	if fs, err := c.FourthQuarter(c.AvailableFilings(FilingType10K)[0]); err != nil {
+		t.Error(err)
+	} else if val, err := fs.Value("Cash Dividends"); err != nil || val != 3189000000 || !fs.IsDerived("Cash Dividends") {
+		t.Error("Incorrect custom metric of the fourth quarter ", val, err)
+	}
+	if fs, err := c.TTM(quarter); err != nil {
+		t.Error(err)
+	} else if val, err := fs.Value("Cash Dividends"); err != nil || val != 13371000000 {
+		t.Error("Incorrect custom metric of the trailing year ", val, err)
+	}
+
+	// JSON is read as well and the metrics are checked
+	if _, err := NewMetricMappings(strings.NewReader(`{"tags": {"msft:UnearnedRevenue": "Deferred revenue"}}`)); err != nil {
+		t.Error("Error reading JSON mappings ", err)
+	}
+	if _, err := NewMetricMappings(strings.NewReader(`{"tags": {"msft:UnearnedRevenue": "Unearned"}}`)); err == nil {
+		t.Error("Expected an error for a tag mapped to an unknown metric")
+	}
+	if _, err := NewMetricMappings(strings.NewReader(`{"metrics": [{"name": "Revenue"}]}`)); err == nil {
+		t.Error("Expected an error for a custom metric named after a metric")
+	}
+
+	// Mappings made in Go are checked with Validate
+	bad := &MetricMappings{Tags: map[string]Metric{"msft:UnearnedRevenue": "Unearned"}}
+	if err := bad.Validate(); err == nil {
+		t.Error("Expected an error for a tag mapped to an unknown metric")
+	}
+	custom := &MetricMappings{Metrics: []CustomMetric{{Name: "Unearned"}}}
+	if err := custom.Validate(); err != nil {
+		t.Error("Unexpected error for a custom metric ", err)
+	}
+	fetcher := NewFilingFetcher(WithMetricMappings(custom), WithMetricMappings(bad)).(*fetcher)
+	if fin, ok := fetcher.client.mappings.metricTags("MSFT").dataType("defref_msft_UnearnedRevenue"); !ok || fin != "Unearned" {
+		t.Error("Expected the tag to map to the custom metric of earlier mappings ", fin)
+	}
+}
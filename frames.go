@@ -0,0 +1,185 @@
+package edgar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var framesURL = "api/xbrl/frames/%s/%s/%s/%s.json"
+
+// The units of the frames of the metrics by the entity of the metrics
+var frameUnits = map[scaleEntity]string{
+	scaleEntityMoney:    "USD",
+	scaleEntityShares:   "shares",
+	scaleEntityPerShare: "USD-per-shares",
+}
+
+// frame is a frames document of EDGAR. It has the value of a concept in
+// a unit reported by every company for a calendar period.
+type frame struct {
+	Taxonomy string `json:"taxonomy"`
+	Tag      string `json:"tag"`
+	CCP      string `json:"ccp"`
+	UOM      string `json:"uom"`
+	Data     []struct {
+		Accn       string  `json:"accn"`
+		CIK        int     `json:"cik"`
+		EntityName string  `json:"entityName"`
+		Start      string  `json:"start"`
+		End        string  `json:"end"`
+		Val        float64 `json:"val"`
+	} `json:"data"`
+}
+
+func frameParser(page io.Reader) (*frame, error) {
+	f := new(frame)
+	if err := json.NewDecoder(page).Decode(f); err != nil {
+		return nil, err
+	}
+	if f.Taxonomy == "" || f.Tag == "" {
+		return nil, errors.New("Not a frames document")
+	}
+	return f, nil
+}
+
+// metricField finds the field of the financial report that a metric is
+// collected into. The field tells the statement and the entity of the
+// metric.
+func metricField(fin finDataType) (filingDocType, scaleEntity, bool) {
+	fr := newFinancialReport(FilingType10K)
+	for doc, data := range map[filingDocType]interface{}{
+		filingDocEN:  fr.Entity,
+		filingDocOps: fr.Ops,
+		filingDocBS:  fr.Bs,
+		filingDocCF:  fr.Cf,
+	} {
+		t := reflect.TypeOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if tag, ok := t.Field(i).Tag.Lookup("json"); ok && tag == string(fin) {
+				return doc, scaleEntity(t.Field(i).Tag.Get("entity")), true
+			}
+		}
+	}
+	return filingDocIg, "", false
+}
+
+// metricConcepts gets the XBRL concepts that a metric is collected from,
+// the preferred concept first. Ex: us-gaap:Revenues
+func metricConcepts(fin finDataType) []string {
+	return xbrlConcepts[fin]
+}
+
+// framePeriod gets the period of the frames of a metric. Metrics of the
+// balance sheet and the entity are reported as of an instant.
+// Ex: CY2023Q1I for the balance sheet as of the end of CY2023Q1
+func framePeriod(doc filingDocType, period string) string {
+	period = strings.ToUpper(strings.TrimSpace(period))
+	if (doc == filingDocBS || doc == filingDocEN) && !strings.HasSuffix(period, "I") {
+		period += "I"
+	}
+	return period
+}
+
+// values gets the values of the frame as values of the metric. The values
+// of companies that already have a value are skipped.
+func (f *frame) values(fin finDataType, resolver CIKResolver, seen map[int]bool) []FrameValue {
+	var ret []FrameValue
+	for _, d := range f.Data {
+		if seen[d.CIK] {
+			continue
+		}
+		seen[d.CIK] = true
+		v := FrameValue{
+			CIK:       fmt.Sprintf("%010d", d.CIK),
+			Name:      d.EntityName,
+			Metric:    Metric(fin),
+			Concept:   f.Taxonomy + ":" + f.Tag,
+			Unit:      f.UOM,
+			Accession: d.Accn,
+			End:       time.Time(getDate(d.End)),
+			Value:     d.Val,
+		}
+		if d.Start != "" {
+			v.Start = time.Time(getDate(d.Start))
+		}
+		if xbrlOutflows[fin] {
+			v.Value = -v.Value
+		}
+		if resolver != nil {
+			if info, err := resolver.LookupCIK(strconv.Itoa(d.CIK)); err == nil {
+				v.Ticker = info.Ticker
+			}
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func sortFrameValues(values []FrameValue) {
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].CIK < values[j].CIK
+	})
+}
+
+// getFrames gets the values of a metric for a period from the frames of
+// every concept that the metric is collected from. Concepts that no
+// company reported for the period have no frame.
+func (c *client) getFrames(
+	ctx context.Context,
+	metric Metric,
+	period string,
+	resolver CIKResolver) ([]FrameValue, error) {
+
+	fin := finDataType(metric)
+	doc, entity, ok := metricField(fin)
+	if !ok {
+		return nil, errors.New("Unknown metric " + string(metric))
+	}
+	period = framePeriod(doc, period)
+
+	var ret []FrameValue
+	seen := make(map[int]bool)
+	for _, concept := range metricConcepts(fin) {
+		parts := strings.SplitN(concept, ":", 2)
+		url := c.dataPageURL(fmt.Sprintf(framesURL, parts[0], parts[1], frameUnits[entity], period))
+		page, err := c.getPage(ctx, url)
+		var notFound *NotFoundError
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		f, err := frameParser(page)
+		page.Close()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, f.values(fin, resolver, seen)...)
+	}
+	sortFrameValues(ret)
+	return ret, nil
+}
+
+// readFrame reads the values of a frames document
+func readFrame(r io.Reader, resolver CIKResolver) ([]FrameValue, error) {
+	f, err := frameParser(r)
+	if err != nil {
+		return nil, err
+	}
+	fin := getFinDataTypeFromXBRLTag("defref_"+f.Taxonomy+"_"+f.Tag, taxonomy(f.Taxonomy))
+	if fin == finDataUnknown {
+		return nil, errors.New("No metric is collected from " + f.Taxonomy + ":" + f.Tag)
+	}
+	ret := f.values(fin, resolver, make(map[int]bool))
+	sortFrameValues(ret)
+	return ret, nil
+}
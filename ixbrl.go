@@ -0,0 +1,320 @@
+package edgar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// Numbers written as words by the ixt-sec:numwordsen transformation
+	ixNumberWords = map[string]float64{
+		"no": 0, "none": 0, "zero": 0, "one": 1, "two": 2, "three": 3,
+		"four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+		"ten": 10,
+	}
+
+	// Layouts of the dates transformed by the ixt date formats
+	ixDateLayouts = []string{
+		"2006-01-02",
+		"January 2, 2006",
+		"January 2 2006",
+		"Jan. 2, 2006",
+		"Jan 2, 2006",
+		"2 January 2006",
+		"01/02/2006",
+		"1/2/2006",
+	}
+)
+
+// ixFact is a fact being read out of an inline XBRL document
+type ixFact struct {
+	tag     string
+	name    string
+	numeric bool
+	attrs   map[string]string
+	text    strings.Builder
+	depth   int
+}
+
+func ixLocalName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func ixAttrs(token html.Token) map[string]string {
+	attrs := make(map[string]string)
+	for _, a := range token.Attr {
+		attrs[a.Key] = a.Val
+	}
+	return attrs
+}
+
+// ixNumber transforms the text of a numeric fact into a number using the
+// ixt transformation format of the fact
+func ixNumber(text string, format string) (float64, error) {
+	text = strings.TrimSpace(text)
+	switch strings.ToLower(ixLocalName(format)) {
+	case "fixed-zero", "zerodash", "fixed-empty", "nocontent":
+		return 0, nil
+	case "numwordsen", "num-word-en":
+		num, ok := ixNumberWords[strings.ToLower(text)]
+		if !ok {
+			return 0, errors.New("Unknown number " + text)
+		}
+		return num, nil
+	case "num-comma-decimal", "numcommadecimal", "numdotcomma", "numspacecomma":
+		text = strings.NewReplacer(".", "", " ", "", " ", "").Replace(text)
+		text = strings.Replace(text, ",", ".", -1)
+	default:
+		text = strings.NewReplacer(",", "", " ", "", " ", "").Replace(text)
+	}
+	if text == "" || text == "-" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// ixDate transforms the text of a date into the YYYY-MM-DD format
+func ixDate(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	for _, layout := range ixDateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return getDateString(t)
+		}
+	}
+	return text
+}
+
+// value gets the value of the fact applying the format, scale and sign
+// of the fact
+func (f *ixFact) value() (string, error) {
+	text := f.text.String()
+	if !f.numeric {
+		if strings.Contains(strings.ToLower(f.attrs["format"]), "date") {
+			return ixDate(text), nil
+		}
+		return strings.TrimSpace(text), nil
+	}
+	num, err := ixNumber(text, f.attrs["format"])
+	if err != nil {
+		return "", err
+	}
+	if scale, err := strconv.Atoi(f.attrs["scale"]); err == nil {
+		num *= math.Pow10(scale)
+	}
+	if f.attrs["sign"] == "-" {
+		num = -num
+	}
+	return strconv.FormatFloat(num, 'f', -1, 64), nil
+}
+
+// inlineXBRLParser reads the contexts, units and facts embedded in an
+// inline XBRL document. Only the numeric facts and the entity information
+// are read out of the document.
+func inlineXBRLParser(page io.Reader) (*xbrlInstance, error) {
+	inst := newXBRLInstance()
+	z := html.NewTokenizer(page)
+
+	var ctx *xbrlContext
+	var unit *xbrlUnit
+	var fact *ixFact
+	var field *string
+	var measure string
+	var measures *[]string
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				break
+			}
+			return nil, z.Err()
+		}
+		token := z.Token()
+
+		// Everything within a fact is the text of the fact
+		if fact != nil {
+			switch {
+			case tt == html.TextToken:
+				fact.text.WriteString(token.Data)
+			case tt == html.StartTagToken && token.Data == fact.tag:
+				fact.depth++
+			case tt == html.EndTagToken && token.Data == fact.tag:
+				if fact.depth > 0 {
+					fact.depth--
+					continue
+				}
+				inst.addFact(fact)
+				fact = nil
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			if field != nil {
+				*field += strings.TrimSpace(token.Data)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch ixLocalName(token.Data) {
+			case "nonfraction", "nonnumeric":
+				fact = &ixFact{
+					tag:     token.Data,
+					name:    ixAttrs(token)["name"],
+					numeric: ixLocalName(token.Data) == "nonfraction",
+					attrs:   ixAttrs(token),
+				}
+				if tt == html.SelfClosingTagToken {
+					inst.addFact(fact)
+					fact = nil
+				}
+			case "context":
+				ctx = &xbrlContext{ID: ixAttrs(token)["id"]}
+			case "segment":
+				if ctx != nil {
+					ctx.Entity.Segment = &struct{}{}
+				}
+			case "scenario":
+				if ctx != nil {
+					ctx.Scenario = &struct{}{}
+				}
+			case "instant":
+				if ctx != nil {
+					field = &ctx.Period.Instant
+				}
+			case "startdate":
+				if ctx != nil {
+					field = &ctx.Period.StartDate
+				}
+			case "enddate":
+				if ctx != nil {
+					field = &ctx.Period.EndDate
+				}
+			case "unit":
+				unit = &xbrlUnit{ID: ixAttrs(token)["id"]}
+				measures = &unit.Measure
+			case "unitnumerator":
+				if unit != nil {
+					measures = &unit.Divide.Numerator
+				}
+			case "unitdenominator":
+				if unit != nil {
+					measures = &unit.Divide.Denominator
+				}
+			case "measure":
+				measure = ""
+				field = &measure
+			}
+		case html.EndTagToken:
+			switch ixLocalName(token.Data) {
+			case "context":
+				if ctx != nil {
+					inst.Contexts[ctx.ID] = *ctx
+				}
+				ctx = nil
+			case "unit":
+				if unit != nil {
+					inst.Units[unit.ID] = unit.String()
+				}
+				unit = nil
+			case "measure":
+				if measures != nil {
+					*measures = append(*measures, measure)
+				}
+				field = nil
+			case "instant", "startdate", "enddate":
+				field = nil
+			}
+		}
+	}
+	if len(inst.Facts) == 0 {
+		return nil, errors.New("No inline XBRL facts found in the document")
+	}
+	return inst, nil
+}
+
+// addFact adds a fact read out of an inline XBRL document to the instance
+func (inst *xbrlInstance) addFact(f *ixFact) {
+	parts := strings.SplitN(f.name, ":", 2)
+	if len(parts) != 2 || f.attrs["contextref"] == "" || f.attrs["xsi:nil"] == "true" {
+		return
+	}
+	// Text blocks and the like are not of interest
+	if !f.numeric && parts[0] != "dei" {
+		return
+	}
+	val, err := f.value()
+	if err != nil {
+		return
+	}
+	inst.Facts = append(inst.Facts, xbrlFact{
+		Key:      "defref_" + parts[0] + "_" + parts[1],
+		Prefix:   parts[0],
+		Context:  f.attrs["contextref"],
+		Unit:     f.attrs["unitref"],
+		Decimals: f.attrs["decimals"],
+		Value:    val,
+	})
+}
+
+// primaryDocument finds the primary document of a filing made with inline
+// XBRL. EDGAR extracts the instance of the primary document into a document
+// of the same name ending with _htm.xml. Otherwise the largest HTML
+// document that is not a rendered report is taken as the primary document.
+func primaryDocument(docs []filingDocument) (string, error) {
+	var primary filingDocument
+	for _, doc := range docs {
+		name := strings.ToLower(doc.Name)
+		if strings.HasSuffix(name, "_htm.xml") {
+			return strings.TrimSuffix(doc.Name, "_htm.xml") + ".htm", nil
+		}
+		var num int
+		if _, err := fmt.Sscanf(name, "r%d.htm", &num); err == nil {
+			continue
+		}
+		if !strings.HasSuffix(name, ".htm") || strings.HasSuffix(name, "-index.htm") {
+			continue
+		}
+		if primary.Name == "" || doc.size() > primary.size() {
+			primary = doc
+		}
+	}
+	if primary.Name == "" {
+		return "", errors.New("No primary document found in the filing")
+	}
+	return primary.Name, nil
+}
+
+// getInlineXBRLData gets the data of a filing from the inline XBRL facts
+// of its primary document. The primary document is looked up in the index
+// of the filing when the link does not name it.
+func (c *client) getInlineXBRLData(ctx context.Context, link filingLink, fileType FilingType, tags *metricTags) (*financialReport, error) {
+	var page io.ReadCloser
+	var err error
+	if link.PrimaryDocument != "" {
+		cik, an := parseCikAndDocID(link.Link)
+		page, err = c.getPage(ctx, c.url(fmt.Sprintf(docURL, cik, an, link.PrimaryDocument)))
+	} else {
+		page, err = c.getFilingDocument(ctx, link.Link, primaryDocument)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inst, err := inlineXBRLParser(page)
+	page.Close()
+	if err != nil {
+		return nil, err
+	}
+	fr := inst.financialReport(fileType, tags)
+	return fr, validateFinancialReport(fr)
+}
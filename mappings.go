@@ -0,0 +1,200 @@
+package edgar
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CustomMetric is a metric collected out of the filings on top of the
+// metrics of the package. Its value is got with Filing.Value.
+type CustomMetric struct {
+	Name Metric `json:"name" yaml:"name"`
+	// Unit is Money, Shares or PerShare and tells how the values of the
+	// metric are scaled in the reports. Defaults to Money
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+}
+
+// MetricMappings map the XBRL tags of the filings to the metrics they are
+// collected as, on top of the tags known to the package. The tags are
+// written as XBRL concepts or as in the reports. Ex: msft:UnearnedRevenue
+// or defref_msft_UnearnedRevenue. The mappings of a company are looked up
+// before the mappings for all companies which are looked up before the
+// tags known to the package.
+type MetricMappings struct {
+	Metrics []CustomMetric `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	// Tags are the mappings for all companies
+	Tags map[string]Metric `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Companies are the mappings of companies by their ticker
+	Companies map[string]map[string]Metric `json:"companies,omitempty" yaml:"companies,omitempty"`
+}
+
+// NewMetricMappings reads the mappings out of a YAML or JSON document.
+// Ex:
+//
+//	metrics:
+//	  - name: Research Expense
+//	tags:
+//	  us-gaap:ResearchAndDevelopmentExpense: Research Expense
+//	companies:
+//	  MSFT:
+//	    msft:UnearnedRevenue: Deferred revenue
+func NewMetricMappings(r io.Reader) (*MetricMappings, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &MetricMappings{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadMetricMappings reads the mappings out of a YAML or JSON file on disk
+func LoadMetricMappings(path string) (*MetricMappings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewMetricMappings(f)
+}
+
+// Validate checks that the custom metrics do not clash with the metrics of
+// the package and that the tags map to the metrics of the package or to the
+// custom metrics of the mappings. Mappings read with NewMetricMappings are
+// already validated.
+func (m *MetricMappings) Validate() error {
+	custom := make(map[Metric]bool)
+	for _, c := range m.Metrics {
+		if c.Name == "" {
+			return errors.New("Custom metric without a name")
+		}
+		if _, _, ok := metricField(finDataType(c.Name)); ok {
+			return errors.New("Custom metric clashes with the metric " + string(c.Name))
+		}
+		if _, ok := customUnit(c.Unit); !ok {
+			return errors.New("Unknown unit " + c.Unit + " of the custom metric " + string(c.Name))
+		}
+		custom[c.Name] = true
+	}
+	check := func(tags map[string]Metric) error {
+		for tag, metric := range tags {
+			if _, _, ok := metricField(finDataType(metric)); !ok && !custom[metric] {
+				return errors.New("Tag " + tag + " is mapped to the unknown metric " + string(metric))
+			}
+		}
+		return nil
+	}
+	if err := check(m.Tags); err != nil {
+		return err
+	}
+	for _, tags := range m.Companies {
+		if err := check(tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customUnit gets the scale entity of the unit of a custom metric
+func customUnit(unit string) (scaleEntity, bool) {
+	switch strings.ToLower(unit) {
+	case "", "money":
+		return scaleEntityMoney, true
+	case "shares":
+		return scaleEntityShares, true
+	case "pershare":
+		return scaleEntityPerShare, true
+	}
+	return "", false
+}
+
+// merge adds the mappings of other to the mappings. The mappings of other
+// win over the mappings for the same tags.
+func (m *MetricMappings) merge(other *MetricMappings) {
+	m.Metrics = append(m.Metrics, other.Metrics...)
+	for tag, metric := range other.Tags {
+		if m.Tags == nil {
+			m.Tags = make(map[string]Metric)
+		}
+		m.Tags[tag] = metric
+	}
+	for ticker, tags := range other.Companies {
+		if m.Companies == nil {
+			m.Companies = make(map[string]map[string]Metric)
+		}
+		ticker = strings.ToUpper(ticker)
+		if m.Companies[ticker] == nil {
+			m.Companies[ticker] = make(map[string]Metric)
+		}
+		for tag, metric := range tags {
+			m.Companies[ticker][tag] = metric
+		}
+	}
+}
+
+// metricTags are the mappings of a company looked up while parsing its
+// filings. The tags are kept as XBRL concepts.
+type metricTags struct {
+	tags   map[string]finDataType
+	custom map[finDataType]scaleEntity
+}
+
+// metricTags gets the mappings of the company with the given ticker. It is
+// nil when there are no mappings.
+func (m *MetricMappings) metricTags(ticker string) *metricTags {
+	if m == nil {
+		return nil
+	}
+	mt := &metricTags{
+		tags:   make(map[string]finDataType),
+		custom: make(map[finDataType]scaleEntity),
+	}
+	for _, c := range m.Metrics {
+		if entity, ok := customUnit(c.Unit); ok {
+			mt.custom[finDataType(c.Name)] = entity
+		}
+	}
+	for tag, metric := range m.Tags {
+		mt.tags[conceptTag(tag)] = finDataType(metric)
+	}
+	for t, tags := range m.Companies {
+		if !strings.EqualFold(t, ticker) {
+			continue
+		}
+		for tag, metric := range tags {
+			mt.tags[conceptTag(tag)] = finDataType(metric)
+		}
+	}
+	if len(mt.tags) == 0 && len(mt.custom) == 0 {
+		return nil
+	}
+	return mt
+}
+
+// dataType gets the metric that a tag is mapped to
+func (mt *metricTags) dataType(key string) (finDataType, bool) {
+	if mt == nil {
+		return finDataUnknown, false
+	}
+	fin, ok := mt.tags[conceptTag(key)]
+	return fin, ok
+}
+
+// customEntity gets the scale entity of a custom metric
+func (mt *metricTags) customEntity(fin finDataType) (scaleEntity, bool) {
+	if mt == nil {
+		return "", false
+	}
+	entity, ok := mt.custom[fin]
+	return entity, ok
+}
@@ -1,73 +1,275 @@
 package edgar
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 var (
-	baseURL   = "https://www.sec.gov/"
-	cikURL    = "https://www.sec.gov/cgi-bin/browse-edgar?action=getcompany&output=xml&CIK=%s"
-	queryURL  = "cgi-bin/browse-edgar?action=getcompany&CIK=%s&type=%s&dateb=&owner=exclude&count=10"
-	searchURL = baseURL + queryURL
+	defaultBaseURL   = "https://www.sec.gov/"
+	defaultDataURL   = "https://data.sec.gov/"
+	defaultPageSize  = 100
+	cikURL           = "cgi-bin/browse-edgar?action=getcompany&output=xml&CIK=%s"
+	queryURL         = "cgi-bin/browse-edgar?action=getcompany&CIK=%s&type=%s&dateb=&owner=exclude&start=%d&count=%d"
 )
 
-func createQueryURL(symbol string, docType FilingType) string {
-	return fmt.Sprintf(searchURL, symbol, docType)
+// client is the transport used for every page fetched from EDGAR.
+// All the network access of the package goes through a client so that
+// the user of the package can control how and where the requests are made
+type client struct {
+	http      *http.Client
+	baseURL   string
+	userAgent string
+	limiter   *rateLimiter
+	retry     retryPolicy
+	cache     *diskCache
+	pageSize  int
+	threshold int
+	discovery Discovery
+	dataURL   string
+	amended   bool
+	parser    Parser
+	mappings  *MetricMappings
 }
 
-func getPage(url string) io.ReadCloser {
-	resp, err := http.Get(url)
+func newClient() *client {
+	return &client{
+		http:      http.DefaultClient,
+		baseURL:   defaultBaseURL,
+		limiter:   newRateLimiter(defaultRateLimit),
+		retry:     newRetryPolicy(),
+		pageSize:  defaultPageSize,
+		threshold: defaultThresholdYear,
+		discovery: DiscoverQueryPage,
+		dataURL:   defaultDataURL,
+		parser:    ParseRendered,
+	}
+}
+
+// url creates an absolute URL out of a path relative to the base URL.
+// Paths that are already absolute are returned as is.
+func (c *client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+func (c *client) createQueryURL(symbol string, docType FilingType, start int) string {
+	return c.url(fmt.Sprintf(queryURL, symbol, docType, start, c.pageSize))
+}
+
+// getPage gets a page from the disk cache if the client has one and the
+// page is in it. Otherwise the page is fetched from EDGAR and cached.
+// The whole page is read before it is handed to the parsers so that a
+// page cut short by a dropped connection is reported as an error instead
+// of being parsed as a page with missing data.
+func (c *client) getPage(ctx context.Context, url string) (io.ReadCloser, error) {
+	if b, ok := c.cache.get(url); ok {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	body, err := c.fetchPage(ctx, url)
 	if err != nil {
-		log.Fatal("Query to SEC page ", url, "failed: ", err)
-		return nil
+		return nil, err
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("Query to SEC page %s failed: %w", url, err)
 	}
-	return resp.Body
+	if err := c.cache.put(url, b); err != nil {
+		log.Println("Failed to cache page " + url + ": " + err.Error())
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
 }
 
-func getCompanyCIK(ticker string) string {
-	url := fmt.Sprintf(cikURL, ticker)
-	r := getPage(url)
-	if r != nil {
-		if cik, err := cikPageParser(r); err == nil {
-			return cik
+// fetchPage fetches a page from EDGAR. Throttled and failed requests are
+// retried as per the retry policy of the client. The error returned for
+// an unsuccessful response is one of the HTTP errors of the package.
+func (c *client) fetchPage(ctx context.Context, url string) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp.Body, nil
+		}
+		resp.Body.Close()
+		err = newHTTPError(url, resp)
+		if !c.retry.retryable(err, attempt) {
+			return nil, err
+		}
+		wait := c.retry.backoff(err, attempt)
+		log.Println(err.Error() + ". Retrying in " + wait.String())
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
 		}
 	}
-	return ""
 }
 
-// getFilingLinks gets the links for filings of a given type of filing 10K/10Q..
-func getFilingLinks(ticker string, fileType FilingType) map[string]string {
-	url := createQueryURL(ticker, fileType)
-	resp := getPage(url)
-	if resp == nil {
-		log.Println("No response on the query for docs")
-		return nil
+func (c *client) doRequest(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
-	defer resp.Close()
-	return queryPageParser(resp, fileType)
+	if c.userAgent == "" {
+		return nil, ErrNoUserAgent
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Query to SEC page %s failed: %w", url, err)
+	}
+	return resp, nil
+}
+
+func (c *client) getCompanyCIK(ctx context.Context, ticker string) (string, error) {
+	url := c.url(fmt.Sprintf(cikURL, ticker))
+	r, err := c.getPage(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return cikPageParser(r)
+}
+
+// getFilingLinks gets the links for filings of the given types of filing
+// 10K/10Q.. using the discovery backend of the client
+func (c *client) getFilingLinks(
+	ctx context.Context,
+	ticker string,
+	cik string,
+	fileTypes ...FilingType) (map[FilingType]map[string]filingLink, error) {
 
+	// The amendments of the filings are collected along with the filings
+	types := withAmendments(fileTypes)
+
+	if c.discovery == DiscoverSubmissions {
+		return c.getSubmissionLinks(ctx, cik, types...)
+	}
+	links := make(map[FilingType]map[string]filingLink)
+	for _, t := range types {
+		if _, ok := links[t]; ok {
+			continue
+		}
+		l, err := c.getQueryLinks(ctx, ticker, t)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range l {
+			links[key] = val
+		}
+	}
+	return links, nil
+}
+
+// getQueryLinks gets the links for filings of a given type of filing 10K/10Q..
+// EDGAR lists the amendments of a type of filing along with the filings.
+// Both are collected unless the type is an amendment itself.
+// EDGAR lists the filings a page at a time, latest first. The pages are
+// walked till the filings go past the threshold year or run out.
+func (c *client) getQueryLinks(ctx context.Context, ticker string, fileType FilingType) (map[FilingType]map[string]filingLink, error) {
+	types := []FilingType{fileType}
+	if !isAmendment(fileType) {
+		types = append(types, amendmentType(fileType))
+	}
+	links := make(map[FilingType]map[string]filingLink)
+	for _, t := range types {
+		links[t] = make(map[string]filingLink)
+	}
+	for start := 0; ; start += c.pageSize {
+		url := c.createQueryURL(ticker, fileType, start)
+		resp, err := c.getPage(ctx, url)
+		if err != nil {
+			log.Println("No response on the query for docs")
+			return nil, err
+		}
+		page, listed, reached := queryPageParser(resp, c.threshold, types...)
+		resp.Close()
+		for t, filings := range page {
+			for key, val := range filings {
+				links[t][key] = newFilingLink(t, key, val)
+			}
+		}
+		if reached || listed < c.pageSize {
+			return links, nil
+		}
+	}
 }
 
 //Get all the docs pages based on the filing type
 //Returns a map:
 // key=Document type ex.Cash flow statement
 // Value = link to that that sheet
-func getFilingDocs(url string, fileType FilingType) map[filingDocType]string {
-	url = baseURL + url
-	resp := getPage(url)
-	if resp == nil {
-		return nil
+func (c *client) getFilingDocs(ctx context.Context, url string, fileType FilingType) (map[filingDocType]string, error) {
+	// The reports are identified by the filing summary when the filing has
+	// one and by the menu of the viewer otherwise
+	cik, an := parseCikAndDocID(url)
+	summary, err := c.getPage(ctx, c.url(fmt.Sprintf(summaryURL, cik, an)))
+	var notFound *NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+	if err == nil {
+		docs, err := filingSummaryParser(summary, "/"+fmt.Sprintf(docURL, cik, an, ""))
+		summary.Close()
+		if err == nil {
+			return docs, nil
+		}
+		log.Println("Failed to parse the filing summary of " + url + ": " + err.Error())
+	}
+
+	resp, err := c.getPage(ctx, c.url(url))
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Close()
 	return filingPageParser(resp, fileType)
 }
 
 // getFinancialData gets the data from all the filing docs and places it in
-// a financial report
-func getFinancialData(url string, fileType FilingType) (*financialReport, error) {
-	docs := getFilingDocs(url, fileType)
-	return parseMappedReports(docs, fileType)
+// a financial report. Filings made without XBRL have no financial data.
+func (c *client) getFinancialData(ctx context.Context, link filingLink, fileType FilingType, tags *metricTags) (*financialReport, error) {
+	if !link.IsXBRL {
+		return nil, fmt.Errorf("Filing on %s: %w", link.FilingDate, errNoFinancialData)
+	}
+	switch c.parser {
+	case ParseXBRL:
+		return c.getXBRLData(ctx, link.Link, fileType, tags)
+	case ParseInlineXBRL:
+		// Filings known to be made without inline XBRL have an instance
+		if link.PrimaryDocument != "" && !link.IsInlineXBRL {
+			return c.getXBRLData(ctx, link.Link, fileType, tags)
+		}
+		return c.getInlineXBRLData(ctx, link, fileType, tags)
+	}
+	docs, err := c.getFilingDocs(ctx, link.Link, fileType)
+	if err != nil {
+		return nil, err
+	}
+	return c.parseMappedReports(ctx, docs, fileType, tags)
+}
+
+// sleep waits for the given duration or till the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
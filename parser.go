@@ -2,6 +2,7 @@ package edgar
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/html"
 )
@@ -19,7 +21,7 @@ func parseCikAndDocID(url string) (string, string) {
 	var d1, d2, d3, d4 int
 	fmt.Sscanf(url, "/cgi-bin/viewer?action=view&cik=%d&accession_number=%d-%d-%d%s", &d1, &d2, &d3, &d4, &s1)
 	cik := fmt.Sprintf("%d", d1)
-	an := fmt.Sprintf("%010d%d%d", d2, d3, d4)
+	an := fmt.Sprintf("%010d%02d%06d", d2, d3, d4)
 	return cik, an
 }
 
@@ -30,26 +32,38 @@ func parseCikAndDocID(url string) (string, string) {
   - There is interactive data available and there is a button that allows the user to click it
   - Since it is a link the tag will be a hyperlink with a button with the id=interactiveDataBtn
   - The actual link is the href attribute in the "a" token just before the id attribute
+  Returns the links of filings of the given types filed in or after the
+  threshold year, the number of filings listed in the page and whether the
+  page went back to filings filed before the threshold year
 */
-func queryPageParser(page io.Reader, docType FilingType) map[string]string {
+func queryPageParser(page io.Reader, threshold int, docTypes ...FilingType) (map[FilingType]map[string]string, int, bool) {
 
-	filingInfo := make(map[string]string)
+	filingInfo := make(map[FilingType]map[string]string)
+	for _, t := range docTypes {
+		filingInfo[t] = make(map[string]string)
+	}
+	listed := 0
+	reached := false
 
 	z := html.NewTokenizer(page)
 
 	data, err := parseTableRow(z, true)
 	for err == nil {
-		//This check for filing type will drop AMEND filings
-		if len(data) == 5 && data[0] == string(docType) {
-			//Drop filings before 2010
-			year := getYear(data[3])
-			if year >= thresholdYear {
-				filingInfo[data[3]] = data[1]
+		//A filing is listed with the filing date before the file number.
+		//Filings without interactive data have no link and one column less
+		if len(data) >= 4 && getYear(data[len(data)-2]) > 0 {
+			listed++
+			//Drop filings before the threshold year
+			filed := data[len(data)-2]
+			if getYear(filed) < threshold {
+				reached = true
+			} else if links, ok := filingInfo[FilingType(data[0])]; ok && len(data) == 5 {
+				links[filed] = data[1]
 			}
 		}
 		data, err = parseTableRow(z, true)
 	}
-	return filingInfo
+	return filingInfo, listed, reached
 }
 
 func cikPageParser(page io.Reader) (string, error) {
@@ -83,7 +97,7 @@ func cikPageParser(page io.Reader) (string, error) {
   - Get the text of the accordian and map the type of the report to the report
   - Create a map of the report to report link
 */
-func filingPageParser(page io.Reader, fileType FilingType) map[filingDocType]string {
+func filingPageParser(page io.Reader, fileType FilingType) (map[filingDocType]string, error) {
 	var filingLinks []string
 	r := bufio.NewReader(page)
 	s, e := r.ReadString('\n')
@@ -92,25 +106,33 @@ func filingPageParser(page io.Reader, fileType FilingType) map[filingDocType]str
 		//Get the number of reports available
 		if strings.Contains(s, "var reports") == true {
 			s1 := strings.Split(s, "(")
+			if len(s1) < 2 {
+				return nil, errors.New("Could not find the number of reports in the filing")
+			}
 			s2 := strings.Split(s1[1], ")")
-			cnt, _ := strconv.Atoi(s2[0])
+			cnt, err := strconv.Atoi(s2[0])
+			if err != nil {
+				return nil, errors.New("Could not find the number of reports in the filing")
+			}
 
 			//cnt-1 because we skip the 'all' in the list
 			for i := 0; i < cnt-1; i++ {
 				if s, e = r.ReadString('\n'); e != nil {
-					panic(e.Error())
+					return nil, errors.New("Could not read the reports of the filing: " + e.Error())
 				}
 				s1 := strings.Split(s, " = ")
+				if len(s1) < 2 {
+					return nil, errors.New("Unknown report in the filing: " + strings.TrimSpace(s))
+				}
 				s2 := strings.Split(s1[1], ";")
 				s3 := strings.Trim(s2[0], "\"")
-				s4 := strings.Split(s3, ".")
 				s5 := s3
 				//Sometimes the report is listed as an xml file??
-				if s4[1] == "xml" {
-					s5 = s4[0] + ".htm"
+				if strings.HasSuffix(s3, ".xml") {
+					s5 = strings.TrimSuffix(s3, ".xml") + ".htm"
 				}
 				if !strings.Contains(s5, "htm") {
-					panic("Dont know this type of report")
+					return nil, errors.New("Unknown type of report in the filing: " + s3)
 				}
 				filingLinks = append(filingLinks, s5)
 			}
@@ -120,9 +142,12 @@ func filingPageParser(page io.Reader, fileType FilingType) map[filingDocType]str
 		s, e = r.ReadString('\n')
 
 	}
+	if len(filingLinks) == 0 {
+		return nil, errors.New("Could not find the reports of the filing")
+	}
 
-	docs := mapReports(page, filingLinks)
-	return docs
+	docs := mapReports(r, filingLinks)
+	return docs, nil
 
 }
 
@@ -271,48 +296,132 @@ func parseTableTitle(z *html.Tokenizer) []string {
 	return strs
 }
 
-func parseTableHeading(z *html.Tokenizer) ([]string, error) {
-	var retData []string
-	//Get the current token
+// reportRow is a row of a report. Numeric tells which of the cells hold
+// the numbers of the report as opposed to text.
+type reportRow struct {
+	cells   []string
+	numeric []bool
+	heading bool
+}
+
+// parseReportRow parses a row of a report keeping the empty cells for the
+// cells to line up with the columns of the report. The cells of a heading
+// are repeated for the columns they span.
+func parseReportRow(z *html.Tokenizer) (reportRow, error) {
+	var row reportRow
 	token := z.Token()
 
-	//Check if this is really a table row
 	for !(token.Type == html.StartTagToken && token.Data == "tr") {
 		tt := z.Next()
 		if tt == html.ErrorToken {
-			return nil, errors.New("Done with parsing")
+			return row, errors.New("Done with parsing")
 		}
 		token = z.Token()
 	}
-
-	//Till the end of the row collect data from each data block
 	for !(token.Data == "tr" && token.Type == html.EndTagToken) {
-
 		if token.Type == html.ErrorToken {
-			return nil, errors.New("Done with parsing")
+			return row, errors.New("Done with parsing")
 		}
-		if token.Data == "th" && token.Type == html.StartTagToken {
-			str := parseTableTitle(z)
-			if len(str) > 0 {
-				retData = append(retData, str...)
+		if token.Type == html.StartTagToken {
+			switch token.Data {
+			case "th":
+				row.heading = true
+				span := 1
+				for _, a := range token.Attr {
+					if a.Key == "colspan" {
+						if n, err := strconv.Atoi(a.Val); err == nil && n > 1 {
+							span = n
+						}
+					}
+				}
+				str := strings.Join(parseTableTitle(z), ", ")
+				for i := 0; i < span; i++ {
+					row.cells = append(row.cells, str)
+					row.numeric = append(row.numeric, false)
+				}
+			case "td":
+				parseFlag := true
+				for _, a := range token.Attr {
+					if a.Key == "class" && (a.Val == "nump" || a.Val == "num") {
+						parseFlag = false
+					}
+				}
+				row.cells = append(row.cells, parseTableData(z, parseFlag))
+				row.numeric = append(row.numeric, !parseFlag)
 			}
 		}
 		z.Next()
 		token = z.Token()
 	}
+	return row, nil
+}
 
-	return retData, nil
+// reportColumn is the period of the values in a column of a report
+type reportColumn struct {
+	end    Timestamp
+	months int
 }
 
-func parseFilingScale(z *html.Tokenizer, t filingDocType) map[scaleEntity]scaleFactor {
-	scales := make(map[scaleEntity]scaleFactor)
-	data, err := parseTableHeading(z)
-	if err == nil {
-		if len(data) > 0 {
-			scales = filingScale(data, t)
+// reportColumns gets the periods of the columns of a report out of the
+// rows of its heading. The last row has the end of the periods and the
+// rows above it the duration. The first cell of the first row is the title
+// of the report. Ex: 3 Months Ended / Jun. 30, 2018
+func reportColumns(heading [][]string) []reportColumn {
+	if len(heading) == 0 {
+		return nil
+	}
+	values := func(row int) []string {
+		if row == 0 && len(heading[0]) > 0 {
+			return heading[0][1:]
+		}
+		return heading[row]
+	}
+	last := values(len(heading) - 1)
+	columns := make([]reportColumn, len(last))
+	for i, str := range last {
+		columns[i].end = reportDate(str)
+	}
+	for row := 0; row < len(heading)-1; row++ {
+		for i, str := range values(row) {
+			var months int
+			if i < len(columns) && reportDuration(str, &months) {
+				columns[i].months = months
+			}
+		}
+	}
+	return columns
+}
+
+// reportDate reads the date in the heading of a column. The date is zero if
+// the heading is not a date. Ex: Jun. 30, 2018
+func reportDate(str string) Timestamp {
+	fields := strings.Fields(strings.Replace(str, ".", "", -1))
+	if len(fields) > 3 {
+		fields = fields[:3]
+	}
+	for _, layout := range []string{"Jan 2, 2006", "January 2, 2006"} {
+		if t, err := time.Parse(layout, strings.Join(fields, " ")); err == nil {
+			return Timestamp(t)
 		}
 	}
-	return scales
+	return Timestamp{}
+}
+
+// reportDuration reads the duration in months in the heading of columns.
+// Ex: 3 Months Ended
+func reportDuration(str string, months *int) bool {
+	var unit string
+	if _, err := fmt.Sscanf(strings.ToLower(str), "%d %s", months, &unit); err != nil {
+		return false
+	}
+	switch unit {
+	case "months", "month":
+		return true
+	case "years", "year":
+		*months *= 12
+		return true
+	}
+	return false
 }
 
 /*
@@ -320,38 +429,122 @@ func parseFilingScale(z *html.Tokenizer, t filingDocType) map[scaleEntity]scaleF
 	for XBRL tags that Filing is interested in to gather and store.
 	XBRL tag is mapped to a finDataType which is then used to lookup
 	the passed in interface fields to see if there is a match and set
-	that field
+	that field. The values of every column are kept with the period of
+	the column.
 */
 
 func finReportParser(page io.Reader, fr *financialReport, t filingDocType) (*financialReport, error) {
+	tbl := readReportTable(page)
+	// A report parsed on its own is looked up in the taxonomy of its tags
+	if fr.taxonomy == "" {
+		fr.taxonomy = detectTaxonomy(tbl.keys)
+	}
+	return finReportTable(tbl, fr, t)
+}
+
+// reportTable is a report read out of its page. The rows are kept till the
+// taxonomy of the filing is known.
+type reportTable struct {
+	heading [][]string
+	rows    []reportRow
+	keys    []string
+}
 
+// readReportTable reads the heading and the rows of a report
+func readReportTable(page io.Reader) *reportTable {
 	z := html.NewTokenizer(page)
-	scales := parseFilingScale(z, t)
-	data, err := parseTableRow(z, true)
+	tbl := &reportTable{}
+	row, err := parseReportRow(z)
 	for err == nil {
-		if len(data) > 0 {
-			finType := getFinDataTypeFromXBRLTag(data[0])
-			if finType != finDataUnknown {
-				for _, str := range data[1:] {
-					if len(str) > 0 {
-						if setData(fr, finType, str, scales, t) == nil {
-							break
-						}
-					}
+		if row.heading {
+			tbl.heading = append(tbl.heading, row.cells)
+		} else if len(row.cells) > 0 && len(row.cells[0]) > 0 {
+			tbl.rows = append(tbl.rows, row)
+			tbl.keys = append(tbl.keys, row.cells[0])
+		}
+		row, err = parseReportRow(z)
+	}
+	return tbl
+}
+
+// finReportTable sets the data of the rows of a report in the financial
+// report. The tags are looked up in the taxonomy of the financial report.
+func finReportTable(tbl *reportTable, fr *financialReport, t filingDocType) (*financialReport, error) {
+	heading, rows := tbl.heading, tbl.rows
+	scales := make(map[scaleEntity]scaleFactor)
+	currency := "USD"
+	if len(heading) > 0 && len(heading[0]) > 0 {
+		scales = filingScale(heading[0], t)
+		if c := reportCurrency(heading[0]); c != "" {
+			currency = c
+		}
+	}
+	columns := reportColumns(heading)
+
+	for _, row := range rows {
+		data := row.cells
+		if t == filingDocEN {
+			for _, str := range data[1:] {
+				if setDocumentInfo(fr.Entity, data[0], str) {
+					break
 				}
 			}
 		}
-		data, err = parseTableRow(z, true)
+		finType := fr.dataType(data[0])
+		if concept, ok := tagConcept(data[0]); ok {
+			unit, entity := conceptUnit(concept, finType, currency)
+			for i, str := range data[1:] {
+				if !row.numeric[i+1] || i >= len(columns) || time.Time(columns[i].end).IsZero() {
+					continue
+				}
+				num, err := normalizeNumber(str)
+				if err != nil {
+					continue
+				}
+				scale := float64(scaleNone)
+				if factor, ok := scales[entity]; ok {
+					scale = float64(factor)
+				}
+				fr.addFact(factValue{
+					Concept: concept,
+					Value:   num * scale,
+					Unit:    unit,
+					Scale:   scale,
+					End:     columns[i].end,
+					Months:  columns[i].months,
+					Report:  string(t),
+				})
+			}
+		}
+		if finType == finDataUnknown {
+			continue
+		}
+		set := false
+		for i, str := range data[1:] {
+			if len(str) == 0 {
+				continue
+			}
+			if !set {
+				set = setData(fr, finType, str, scales, t) == nil
+			}
+			if i < len(columns) && !time.Time(columns[i].end).IsZero() {
+				fr.addPeriod(finType, columns[i].end, columns[i].months, str, scales, t)
+			}
+		}
 	}
 	return fr, nil
 }
 
 // parseAllReports gets all the reports filed under a given account normalizeNumber
-func parseAllReports(cik string, an string) []int {
+func (c *client) parseAllReports(ctx context.Context, cik string, an string) ([]int, error) {
 
 	var reports []int
-	url := "https://www.sec.gov/Archives/edgar/data/" + cik + "/" + an + "/"
-	page := getPage(url)
+	url := c.url("Archives/edgar/data/" + cik + "/" + an + "/")
+	page, err := c.getPage(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer page.Close()
 	z := html.NewTokenizer(page)
 	data, err := parseTableRow(z, false)
 	for err == nil {
@@ -367,22 +560,49 @@ func parseAllReports(cik string, an string) []int {
 	sort.Slice(reports, func(i, j int) bool {
 		return reports[i] < reports[j]
 	})
-	return reports
+	return reports, nil
 }
 
-func parseMappedReports(docs map[filingDocType]string, docType FilingType) (*financialReport, error) {
+func (c *client) parseMappedReports(ctx context.Context, docs map[filingDocType]string, docType FilingType, tags *metricTags) (*financialReport, error) {
 	var wg sync.WaitGroup
+	var m sync.Mutex
+	var fetchErr error
 	fr := newFinancialReport(docType)
+	fr.tags = tags
+	tables := make(map[filingDocType]*reportTable)
 	for t, url := range docs {
 		wg.Add(1)
-		go func(url string, fr *financialReport, t filingDocType) {
+		go func(url string, t filingDocType) {
 			defer wg.Done()
-			page := getPage(url)
-			if page != nil {
-				finReportParser(page, fr, t)
+			page, err := c.getPage(ctx, url)
+			if err != nil {
+				m.Lock()
+				fetchErr = err
+				m.Unlock()
+				return
 			}
-		}(baseURL+url, fr, t)
+			defer page.Close()
+			tbl := readReportTable(page)
+			m.Lock()
+			tables[t] = tbl
+			m.Unlock()
+		}(c.url(url), t)
 	}
 	wg.Wait()
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	// The taxonomy is detected once out of the tags of all the reports
+	var keys []string
+	for _, tbl := range tables {
+		keys = append(keys, tbl.keys...)
+	}
+	fr.taxonomy = detectTaxonomy(keys)
+	for t, tbl := range tables {
+		finReportTable(tbl, fr, t)
+	}
+	if originalType(docType) == FilingType10Q {
+		fr.quarterly()
+	}
 	return fr, validateFinancialReport(fr)
 }
@@ -1,13 +1,22 @@
 package edgar
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/palafrank/edgar/edgartest"
 	"golang.org/x/net/html"
 )
 
@@ -68,6 +77,62 @@ func TestParsingXBRLDef(t *testing.T) {
 	}
 }
 
+func TestTaxonomyTags(t *testing.T) {
+	ifrs := []string{"defref_ifrs-full_Revenue", "defref_sap_CloudRevenue", "defref_ifrs-full_ProfitLoss"}
+	gaap := []string{"defref_us-gaap_Revenues", "defref_msft_Equity", "defref_dei_EntityCommonStockSharesOutstanding"}
+	if detectTaxonomy(ifrs) != taxonomyIFRS || detectTaxonomy(gaap) != taxonomyGAAP {
+		t.Error("Incorrect taxonomy detected")
+	}
+	if getFinDataTypeFromXBRLTag("defref_ifrs-full_NoncurrentBorrowings", taxonomyIFRS) != finDataLDebt {
+		t.Error("IFRS tag not mapped")
+	}
+	// The total borrowings are both current and non-current debt
+	if getFinDataTypeFromXBRLTag("defref_ifrs-full_Borrowings", taxonomyIFRS) != finDataUnknown {
+		t.Error("Total borrowings mapped to long term debt")
+	}
+	// Company specific tags are looked up in the taxonomy of the filing
+	if getFinDataTypeFromXBRLTag("defref_sap_Equity", taxonomyIFRS) != finDataTotalEquity {
+		t.Error("Company specific IFRS tag not mapped")
+	}
+	if getFinDataTypeFromXBRLTag("defref_msft_Equity", taxonomyGAAP) != finDataUnknown {
+		t.Error("IFRS tag mapped in a GAAP filing")
+	}
+	if getFinDataTypeFromXBRLTag("defref_dei_EntityCommonStockSharesOutstanding", taxonomyIFRS) != finDataSharesOutstanding {
+		t.Error("Entity tag not mapped in an IFRS filing")
+	}
+}
+
+func TestFilingTaxonomy(t *testing.T) {
+	row := func(tag, val string) string {
+		return `<tr class="re"><td class="pl"><a onclick="top.Show.showAR( this, '` + tag + `', window );">` +
+			tag + `</a></td><td class="nump">` + val + `</td></tr>`
+	}
+	heading := `<tr><th class="tl"><div><strong>STATEMENT - EUR (€)<br> € in Millions</strong></div></th>` +
+		`<th class="th"><div>Jun. 30, 2018</div></th></tr>`
+	pages := map[string]string{
+		"/R2.htm": `<table>` + heading + row("defref_ifrs-full_Revenue", "6,000") + row("defref_ifrs-full_ProfitLoss", "900") + `</table>`,
+		// The balance sheet only has a company specific tag
+		"/R4.htm": `<table>` + heading + row("defref_sap_Equity", "25,000") + `</table>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	f := NewFilingFetcher(WithBaseURL(server.URL), WithUserAgent(testUserAgent)).(*fetcher)
+	docs := map[filingDocType]string{filingDocOps: "R2.htm", filingDocBS: "R4.htm"}
+	fr, _ := f.client.parseMappedReports(context.Background(), docs, FilingType10K, nil)
+	if fr == nil {
+		t.Fatal("Failed to parse the reports")
+	}
+	if fr.taxonomy != taxonomyIFRS {
+		t.Error("Incorrect taxonomy of the filing ", fr.taxonomy)
+	}
+	if fr.Bs.Equity == 0 {
+		t.Error("Company specific tag not looked up in the taxonomy of the filing")
+	}
+}
+
 func TestParsingNumInLink(t *testing.T) {
 	page := strings.NewReader(sampleRowWithNumInLink)
 	z := html.NewTokenizer(page)
@@ -89,13 +154,18 @@ func TestParsingNumInLink(t *testing.T) {
 }
 
 func TestGetCIK(t *testing.T) {
-	cik := getCompanyCIK("MSFT")
+	s := edgartest.NewServer()
+	defer s.Close()
+	s.AddCompany(edgartest.Company{Ticker: "MSFT", CIK: "0000789019"})
+	s.AddCompany(edgartest.Company{Ticker: "GE", CIK: "0000040545"})
+	c := sampleFetcher(s).(*fetcher).client
+	cik, _ := c.getCompanyCIK(context.Background(), "MSFT")
 	if cik != "0000789019" {
 		t.Error("Incorrect CIK parser for MSFT - ", cik)
 	}
-	cik = getCompanyCIK("GE")
+	cik, _ = c.getCompanyCIK(context.Background(), "GE")
 	if cik != "0000040545" {
-		t.Error("Incorrect CIK parser for MSFT - ", cik)
+		t.Error("Incorrect CIK parser for GE - ", cik)
 	}
 }
 
@@ -113,10 +183,11 @@ func TestFilingQuery(t *testing.T) {
 		"2015-07-22": "/cgi-bin/viewer?action=view&cik=320193&accession_number=0001193125-15-259935&xbrl_type=v",
 	}
 	f, _ := os.Open("samples/sample_query.html")
-	links := queryPageParser(f, FilingType10Q)
+	filings, listed, _ := queryPageParser(f, defaultThresholdYear, FilingType10Q)
 	f.Close()
-	if len(links) != 10 {
-		t.Error("Incorrect number of filing links found ", len(links))
+	links := filings[FilingType10Q]
+	if len(links) != 10 || listed != 10 {
+		t.Error("Incorrect number of filing links found ", len(links), listed)
 	}
 
 	for key, val := range links {
@@ -133,6 +204,11 @@ func TestParseCIKAndDocID(t *testing.T) {
 	if s1 != "320193" || s2 != "000119312515259935" {
 		t.Error("Error in parsing CIK and doc id ", s1, s2)
 	}
+	str2 := "/cgi-bin/viewer?action=view&cik=320193&accession_number=0000320193-09-000100&xbrl_type=v"
+	s1, s2 = parseCikAndDocID(str2)
+	if s1 != "320193" || s2 != "000032019309000100" {
+		t.Error("Error in parsing CIK and doc id with leading zeros ", s1, s2)
+	}
 }
 
 func TestFiling10QParser(t *testing.T) {
@@ -144,8 +220,11 @@ func TestFiling10QParser(t *testing.T) {
 		filingDocBS:  "/Archives/edgar/data/320193/000032019318000100/R5.htm",
 	}
 	f, _ := os.Open("samples/sample_10Q.html")
-	docs := filingPageParser(f, FilingType10Q)
+	docs, err := filingPageParser(f, FilingType10Q)
 	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for key, val := range check {
 		if docs[key] != val {
 			t.Error("Did not get the expected number of filing document in the 10K")
@@ -162,8 +241,11 @@ func TestFiling10KParser(t *testing.T) {
 		filingDocBS:  "/Archives/edgar/data/320193/000119312515356351/R5.htm",
 	}
 	f, _ := os.Open("samples/sample_10K.html")
-	docs := filingPageParser(f, FilingType10K)
+	docs, err := filingPageParser(f, FilingType10K)
 	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for key, val := range check {
 		if docs[key] != val {
 			t.Error("Did not get the expected number of filing document in the 10K")
@@ -171,10 +253,93 @@ func TestFiling10KParser(t *testing.T) {
 	}
 }
 
+func TestFiling20FParser(t *testing.T) {
+	var check = map[filingDocType]string{
+		filingDocCF:  "/Archives/edgar/data/1000184/000100018419000010/R6.htm",
+		filingDocInc: "/Archives/edgar/data/1000184/000100018419000010/R2.htm",
+		filingDocEN:  "/Archives/edgar/data/1000184/000100018419000010/R1.htm",
+		filingDocBS:  "/Archives/edgar/data/1000184/000100018419000010/R4.htm",
+	}
+	f, _ := os.Open("samples/sample_20F.html")
+	docs, err := filingPageParser(f, FilingType20F)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, val := range check {
+		if docs[key] != val {
+			t.Error("Incorrect filing document in the 20F ", key, docs[key])
+		}
+	}
+}
+
+func TestFilingSummaryParser(t *testing.T) {
+	base := "/Archives/edgar/data/320193/000032019318000100/"
+	var check = map[filingDocType]string{
+		filingDocEN:     base + "R1.htm",
+		filingDocOps:    base + "R2.htm",
+		filingDocInc:    base + "R3.htm",
+		filingDocBS:     base + "R5.htm",
+		filingDocCF:     base + "R7.htm",
+		filingDocDebt:   base + "R12.htm",
+		filingDocEquity: base + "R13.htm",
+	}
+	f, _ := os.Open("samples/sample_10Q_summary.xml")
+	docs, err := filingSummaryParser(f, base)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, val := range check {
+		if docs[key] != val {
+			t.Error("Incorrect filing document in the summary ", key, docs[key])
+		}
+	}
+	// Parenthetical statements and the notes that are not collected are ignored
+	for _, val := range docs {
+		if val == base+"R4.htm" || val == base+"R6.htm" || val == base+"R14.htm" {
+			t.Error("Incorrect filing document in the summary ", val)
+		}
+	}
+
+	// Reports with roles that do not describe them are identified by name
+	summary := `<FilingSummary><MyReports>
+<Report><XmlFileName>R1.xml</XmlFileName><LongName>0001 - Document - Document and Entity Information</LongName><ShortName>Document and Entity Information</ShortName><Position>1</Position><Role>http://www.sap.com/role/Document1</Role></Report>
+<Report><XmlFileName>R2.xml</XmlFileName><LongName>0002 - Statement - Consolidated Income Statements</LongName><ShortName>Consolidated Income Statements</ShortName><Position>2</Position><Role>http://www.sap.com/role/Statement2</Role></Report>
+<Report><XmlFileName>R4.xml</XmlFileName><LongName>0004 - Statement - Consolidated Statements of Financial Position</LongName><ShortName>Consolidated Statements of Financial Position</ShortName><Position>4</Position><Role>http://www.sap.com/role/Statement4</Role></Report>
+<Report><XmlFileName>R3.xml</XmlFileName><LongName>0003 - Statement - Consolidated Statements of Comprehensive Income</LongName><ShortName>Consolidated Statements of Comprehensive Income</ShortName><Position>3</Position><Role>http://www.sap.com/role/StatementOfComprehensiveIncome</Role></Report>
+</MyReports></FilingSummary>`
+	docs, err = filingSummaryParser(strings.NewReader(summary), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs[filingDocEN] != "R1.htm" || docs[filingDocInc] != "R2.htm" || docs[filingDocBS] != "R4.htm" {
+		t.Error("Incorrect filing documents identified by name ", docs)
+	}
+
+	if _, err := filingSummaryParser(strings.NewReader("<FilingSummary></FilingSummary>"), ""); err == nil {
+		t.Error("Expected an error for a summary without statements")
+	}
+}
+
+func TestFilingPageParserErrors(t *testing.T) {
+	pages := []string{
+		"<html></html>\n",
+		"var reports = new Array(3);\nreports[0] = \"R1.htm\";\n",
+		"var reports = new Array(2);\nreports[0] = \"R1.pdf\";\n",
+		"var reports = new Array(x);\n",
+	}
+	for _, page := range pages {
+		if _, err := filingPageParser(strings.NewReader(page), FilingType10Q); err == nil {
+			t.Error("Expected an error for the filing page ", page)
+		}
+	}
+}
+
 func TestParsingReports(t *testing.T) {
 	url := "cgi-bin/viewer?action=view&cik=789019&accession_number=0001193125-13-310206&xbrl_type=v"
 	for i := 0; i < 1; i++ {
-		report, err := getFinancialData(url, FilingType10K)
+		report, err := liveFetcher(t, "MSFT-2013").client.getFinancialData(context.Background(), newFilingLink(FilingType10K, "", url), FilingType10K, nil)
 		if err != nil {
 			t.Error("Failed to parse financial data: ", err.Error())
 			return
@@ -236,8 +401,11 @@ func TestFiling10KParser1(t *testing.T) {
 		filingDocBS:  "/Archives/edgar/data/320193/000119312511282113/R3.htm",
 	}
 	f, _ := os.Open("samples/sample_10K_1.html")
-	docs := filingPageParser(f, FilingType10K)
+	docs, err := filingPageParser(f, FilingType10K)
 	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for key, val := range check {
 		if docs[key] != val {
 			t.Error("Did not get the expected number of filing document in the 10K")
@@ -262,6 +430,36 @@ func TestEntityParser(t *testing.T) {
 	} else if data, _ := file.ShareCount(); data != 4829926000 {
 		t.Error("Incorrect sharecount value parsed ", data)
 	}
+	if end, _ := file.PeriodEnd(); getDateString(end) != "2018-06-30" {
+		t.Error("Incorrect period end parsed ", end)
+	}
+	if year, _ := file.FiscalYear(); year != 2018 {
+		t.Error("Incorrect fiscal year parsed ", year)
+	}
+	if period, _ := file.FiscalPeriod(); period != "Q3" {
+		t.Error("Incorrect fiscal period parsed ", period)
+	}
+	if file.FinData.Entity.FiscalYearEnd != "--09-29" {
+		t.Error("Incorrect fiscal year end parsed ", file.FinData.Entity.FiscalYearEnd)
+	}
+
+	// The period of the filing is saved with the filing
+	var saved filing
+	if err := json.Unmarshal([]byte(file.String()), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if end, err := saved.PeriodEnd(); err != nil || getDateString(end) != "2018-06-30" {
+		t.Error("Incorrect period end of the saved filing ", end, err)
+	}
+	if period, _ := saved.FiscalPeriod(); period != "Q3" {
+		t.Error("Incorrect fiscal period of the saved filing ", period)
+	}
+
+	var empty filing
+	empty.FinData = newFinancialReport(FilingType10Q)
+	if _, err := empty.PeriodEnd(); err == nil {
+		t.Error("Expected an error for a filing without a period end")
+	}
 }
 
 func TestEntity1Parser(t *testing.T) {
@@ -288,6 +486,15 @@ func Test10KEntityParser(t *testing.T) {
 	} else if val, _ := file.ShareCount(); val != 5575331000 {
 		t.Error("Incorrect sharecount value parsed ", val)
 	}
+	if end, _ := file.PeriodEnd(); getDateString(end) != "2015-09-26" {
+		t.Error("Incorrect period end parsed ", end)
+	}
+	if year, _ := file.FiscalYear(); year != 2015 {
+		t.Error("Incorrect fiscal year parsed ", year)
+	}
+	if period, _ := file.FiscalPeriod(); period != "FY" {
+		t.Error("Incorrect fiscal period parsed ", period)
+	}
 }
 
 /*
@@ -329,10 +536,14 @@ func TestOpsParser(t *testing.T) {
 func TestOps1Parser(t *testing.T) {
 	fmt.Println("*** Income Parser testing ***")
 	doc := "https://www.sec.gov//Archives/edgar/data/789019/000119312511200680/R2.htm"
-	f := getPage(doc)
+	f, err := liveFetcher(t, "MSFT-2011-Ops").client.getPage(context.Background(), doc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
 	var file filing
 	file.FinData = newFinancialReport(FilingType10K)
-	_, err := finReportParser(f, file.FinData, filingDocOps)
+	_, err = finReportParser(f, file.FinData, filingDocOps)
 	f.Close()
 	if err != nil {
 		t.Error("Error parsing net income sheet ", err.Error())
@@ -346,10 +557,14 @@ func TestOps1Parser(t *testing.T) {
 func TestOps2Parser(t *testing.T) {
 	fmt.Println("*** Income Parser testing ***")
 	doc := "https://www.sec.gov//Archives/edgar/data/1534701/000153470118000065/R2.htm"
-	f := getPage(doc)
+	f, err := liveFetcher(t, "PSX-2018-Ops").client.getPage(context.Background(), doc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
 	var file filing
 	file.FinData = newFinancialReport(FilingType10K)
-	_, err := finReportParser(f, file.FinData, filingDocOps)
+	_, err = finReportParser(f, file.FinData, filingDocOps)
 	f.Close()
 	if err != nil {
 		t.Error("Error parsing net income sheet ", err.Error())
@@ -402,6 +617,265 @@ func Test10KOpsParser(t *testing.T) {
 	Cash Flow parsing testcases
 */
 
+func Test20FParser(t *testing.T) {
+	fr := newFinancialReport(FilingType20F)
+	f, _ := os.Open("samples/sample_20F_ops.html")
+	finReportParser(f, fr, filingDocInc)
+	f.Close()
+	f, _ = os.Open("samples/sample_20F_bs.html")
+	finReportParser(f, fr, filingDocBS)
+	f.Close()
+	if fr.Ops.Revenue != 24708000000 {
+		t.Error("Revenue amount did not match ", fr.Ops.Revenue)
+	}
+	if fr.Ops.CostOfSales != -7462000000 {
+		t.Error("Cost of Sales amount did not match ", fr.Ops.CostOfSales)
+	}
+	if fr.Ops.OpIncome != 5703000000 {
+		t.Error("Operational Income amount did not match ", fr.Ops.OpIncome)
+	}
+	if fr.Ops.NetIncome != 4088000000 {
+		t.Error("Net income amount did not match ", fr.Ops.NetIncome)
+	}
+	if fr.Ops.WAShares != 1195000000 {
+		t.Error("Weighted average shares did not match ", fr.Ops.WAShares)
+	}
+	if fr.Bs.Assets != 51502000000 || fr.Bs.Equity != 27800000000 || fr.Bs.Cash != 8627000000 {
+		t.Error("Balance sheet amounts did not match ", fr.Bs)
+	}
+
+	scales := filingScale([]string{"Consolidated Statements of Profit or Loss - EUR (€)", "€ in Thousands"}, filingDocInc)
+	if scales[scaleEntityMoney] != scaleThousand {
+		t.Error("Incorrect scale for EUR ", scales)
+	}
+}
+
+func TestCurrencies(t *testing.T) {
+	numbers := map[string]float64{"$ 2.36": 2.36, "NT$ 2.36": 2.36, "€1,234": 1234, "EUR 1,234": 1234, "usd (12)": -12, "(12)": -12}
+	for str, expected := range numbers {
+		if num, err := normalizeNumber(str); err != nil || num != expected {
+			t.Error("Incorrect number for ", str, num, err)
+		}
+	}
+	// Only the currency codes and symbols are removed
+	for _, str := range []string{"Two 2.36", "approx 12", "n/a"} {
+		if num, err := normalizeNumber(str); err == nil {
+			t.Error("Expected an error for ", str, num)
+		}
+	}
+	if !isCurrency("usd ($)") || !isCurrency("€ in millions") || !isCurrency("cny") {
+		t.Error("Currency not found in the heading")
+	}
+	// The codes are matched as whole words
+	for _, str := range []string{"financial instruments", "education", "in thousands except per share data", "european operations"} {
+		if isCurrency(str) {
+			t.Error("Currency found in ", str)
+		}
+	}
+	if c := reportCurrency([]string{"Consolidated Balance Sheets - TWD (NT$)", "NT$ in Millions"}); c != "TWD" {
+		t.Error("Incorrect currency of the report ", c)
+	}
+	if c := reportCurrency([]string{"Consolidated Balance Sheets", "In Millions"}); c != "" {
+		t.Error("Currency found in the heading ", c)
+	}
+}
+
+func TestReportPeriods(t *testing.T) {
+	f, _ := os.Open("samples/sample_ops.html")
+	var file filing
+	file.FinData = newFinancialReport(FilingType10Q)
+	_, err := finReportParser(f, file.FinData, filingDocOps)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every column is kept with its period, the latest and shortest first
+	revenue := file.PeriodValues(MetricRevenue)
+	expected := []PeriodValue{
+		{MetricRevenue, time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC), 3, 53265000000},
+		{MetricRevenue, time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC), 9, 202695000000},
+		{MetricRevenue, time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC), 3, 45408000000},
+		{MetricRevenue, time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC), 9, 176655000000},
+	}
+	if !reflect.DeepEqual(revenue, expected) {
+		t.Error("Incorrect revenue periods ", revenue)
+	}
+	if data, _ := file.Revenue(); data != 53265000000 {
+		t.Error("Incorrect revenue of the filing ", data)
+	}
+
+	f, _ = os.Open("samples/sample_bs.html")
+	_, err = finReportParser(f, file.FinData, filingDocBS)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets := file.PeriodValues(MetricCurrentAssets)
+	if len(assets) != 2 || assets[0].Value != 115761000000 || assets[1].Value != 128645000000 {
+		t.Fatal("Incorrect current assets periods ", assets)
+	}
+	if assets[1].Months != 0 || !assets[1].End.Equal(time.Date(2017, 9, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Incorrect period of the prior balance sheet ", assets[1])
+	}
+
+	// The quarter is kept for a quarterly report. The cash flows are only
+	// reported for the nine months to date.
+	f, _ = os.Open("samples/sample_cf.html")
+	_, err = finReportParser(f, file.FinData, filingDocCF)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.FinData.quarterly()
+	if data, _ := file.Revenue(); data != 53265000000 {
+		t.Error("Incorrect revenue of the quarter ", data)
+	}
+	if _, err := file.OperatingCashFlow(); err == nil || !file.FinData.quarterMissing() {
+		t.Error("Expected the operating cash flow of the quarter to be missing")
+	}
+
+	// The year to date of the previous quarter gives the quarter
+	prev := newFinancialReport(FilingType10Q)
+	prev.addPeriod(finDataOpCashFlow, getDate("2018-03-31"), 6, "43,423", map[scaleEntity]scaleFactor{scaleEntityMoney: scaleMillion}, filingDocCF)
+	file.FinData.deriveQuarter(prev)
+	if data, _ := file.OperatingCashFlow(); data != 14488000000 || !file.IsDerived(MetricOperatingCashFlow) {
+		t.Error("Incorrect operating cash flow of the quarter ", data)
+	}
+	if _, err := file.CapitalExpenditure(); err == nil {
+		t.Error("Expected the capital expenditure of the quarter to be missing")
+	}
+}
+
+func TestXBRLInstanceParser(t *testing.T) {
+	f, _ := os.Open("samples/sample_10Q.xml")
+	inst, err := xbrlInstanceParser(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.periodEnd() != "2018-06-30" {
+		t.Error("Incorrect period of report ", inst.periodEnd())
+	}
+	if inst.Units["usdPerShare"] != "iso4217:USD/xbrli:shares" {
+		t.Error("Incorrect unit ", inst.Units["usdPerShare"])
+	}
+
+	fr := inst.financialReport(FilingType10Q, nil)
+	// Facts for the quarter are picked over the facts for the year to
+	// date, other periods and segments of the business
+	if fr.Ops.Revenue != 53265000000 || fr.Ops.NetIncome != 11519000000 {
+		t.Error("Incorrect operations data ", fr.Ops)
+	}
+	if fr.Ops.Dps != 0.73 || fr.Ops.WAShares != 4926609000 {
+		t.Error("Incorrect per share data ", fr.Ops)
+	}
+	// The most precise fact is picked
+	if fr.Bs.Assets != 349197000000 || fr.Bs.Cash != 31971000000 || fr.Bs.Equity != 114949000000 {
+		t.Error("Incorrect balance sheet data ", fr.Bs)
+	}
+	// Cash flows are only reported for the year to date in a 10-Q. The
+	// quarter is left to be derived from the previous quarter.
+	if fr.Cf.CollectedData != 0 || !fr.quarterMissing() {
+		t.Error("Incorrect cash flow data ", fr.Cf)
+	}
+	if capex := (&filing{FinData: fr}).PeriodValues(MetricCapitalExpenditure); len(capex) == 0 ||
+		capex[0].Value != -10272000000 || capex[0].Months != 9 {
+		t.Error("Incorrect capital expenditure for the year to date ", capex)
+	}
+	if fr.Entity.ShareCount != 4829926000 {
+		t.Error("Incorrect share count ", fr.Entity.ShareCount)
+	}
+	if fr.Entity.PeriodEnd == nil || fr.Entity.PeriodEnd.String() != "2018-06-30" ||
+		fr.Entity.FiscalYear != 2018 || fr.Entity.FiscalPeriod != "Q3" {
+		t.Error("Incorrect document information ", fr.Entity)
+	}
+
+	file := filing{FinData: fr}
+	if revenue := file.PeriodValues(MetricRevenue); len(revenue) < 2 ||
+		revenue[0].Value != 53265000000 || revenue[0].Months != 3 ||
+		revenue[1].Value != 202695000000 || revenue[1].Months != 9 {
+		t.Error("Incorrect revenue periods ", revenue)
+	}
+	// Facts are kept with their units. Nil facts are not values.
+	if _, err := file.Fact("us-gaap:AccumulatedOtherComprehensiveIncomeLossNetOfTax"); err == nil {
+		t.Error("Expected no fact for a nil fact")
+	}
+	if assets, err := file.Fact("us-gaap:Assets"); err != nil || assets.Value != 349197000000 ||
+		assets.Unit != "USD" || assets.Months != 0 || assets.Report != "" {
+		t.Error("Incorrect assets fact ", assets, err)
+	}
+	if dps, err := file.Fact("us-gaap:CommonStockDividendsPerShareDeclared"); err != nil ||
+		dps.Value != 0.73 || dps.Unit != "USD/shares" || dps.Months != 3 {
+		t.Error("Incorrect dividend per share fact ", dps, err)
+	}
+
+	// Annual reports pick the longest period
+	fr = inst.financialReport(FilingType10K, nil)
+	if fr.Ops.Revenue != 202695000000 {
+		t.Error("Incorrect annual revenue ", fr.Ops.Revenue)
+	}
+}
+
+func TestInlineXBRLParser(t *testing.T) {
+	f, _ := os.Open("samples/sample_10Q_ixbrl.htm")
+	inst, err := inlineXBRLParser(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.periodEnd() != "2018-06-30" {
+		t.Error("Incorrect period of report ", inst.periodEnd())
+	}
+	if inst.Units["usdPerShare"] != "iso4217:USD/xbrli:shares" {
+		t.Error("Incorrect unit ", inst.Units["usdPerShare"])
+	}
+	if !inst.Contexts["FI2018Q3QTD_srt_ProductOrServiceAxis_us-gaap_ProductMember"].dimensional() {
+		t.Error("Segment of the context was not read")
+	}
+
+	// Scale, sign and the transformation formats are applied to the facts
+	facts := make(map[string]string)
+	for _, fact := range inst.Facts {
+		facts[fact.Key+" "+fact.Context] = fact.Value
+	}
+	tests := map[string]string{
+		"defref_us-gaap_NetIncomeLoss FI2018Q3QTD":                                "11519000000",
+		"defref_us-gaap_NonoperatingIncomeExpense FI2018Q3QTD":                    "-672000000",
+		"defref_us-gaap_AccumulatedOtherComprehensiveIncomeLossNetOfTax FI2018Q3": "0",
+		"defref_us-gaap_PaymentsToAcquireBusinessesNetOfCashAcquired FI2018Q3YTD": "0",
+		"defref_dei_EntityCommonStockSharesOutstanding I2018Q3_cover":             "4829926000",
+		"defref_dei_DocumentType FI2018Q3YTD":                                     "10-Q",
+	}
+	for key, val := range tests {
+		if facts[key] != val {
+			t.Error("Incorrect value of ", key, " ", facts[key])
+		}
+	}
+	if _, ok := facts["defref_us-gaap_IncomeTaxDisclosureTextBlock FI2018Q3YTD"]; ok {
+		t.Error("Text blocks should not be read")
+	}
+
+	fr := inst.financialReport(FilingType10Q, nil)
+	if fr.Ops.Revenue != 53265000000 || fr.Ops.NetIncome != 11519000000 {
+		t.Error("Incorrect operations data ", fr.Ops)
+	}
+	if fr.Ops.Dps != 0.73 || fr.Ops.WAShares != 4926609000 {
+		t.Error("Incorrect per share data ", fr.Ops)
+	}
+	if fr.Bs.Assets != 349197000000 || fr.Bs.Equity != 114949000000 {
+		t.Error("Incorrect balance sheet data ", fr.Bs)
+	}
+	if fr.Cf.CollectedData != 0 || !fr.quarterMissing() {
+		t.Error("Incorrect cash flow data ", fr.Cf)
+	}
+	if fr.Entity.ShareCount != 4829926000 {
+		t.Error("Incorrect share count ", fr.Entity.ShareCount)
+	}
+	if fr.Entity.PeriodEnd == nil || fr.Entity.PeriodEnd.String() != "2018-06-30" {
+		t.Error("Incorrect period end ", fr.Entity.PeriodEnd)
+	}
+}
+
 func TestCfParser(t *testing.T) {
 	fmt.Println("*** Cash flow parser testing ***")
 	f, _ := os.Open("samples/sample_cf.html")
@@ -561,7 +1035,7 @@ func SkipTestFinReportMarshal(t *testing.T) {
 	file.Company = "AAPL"
 	file.FinData = newFinancialReport(FilingType10K)
 
-	comp := newCompany("AAPL")
+	comp := newCompany(newClient(), "AAPL")
 	comp.AddReport(&file)
 
 	data := file.FinData
@@ -616,16 +1090,39 @@ func SkipTestFolderReader(t *testing.T) {
 }
 
 // LIVE TESTS:
-//     These tests are run live against EDGAR website. They are commented out
-//     to avoid hitting the site during repeated unit testing.
-//     Uncomment them when a live test is needed to verify something that is
-//     not covered in the samples.
+//     These tests are scenarios run against the EDGAR website. The pages
+//     of every scenario recorded under testdata/live are replayed to avoid
+//     hitting the site during repeated unit testing.
+//     Run the tests with -live (and EDGAR_USER_AGENT set to a declared user
+//     agent) to run the scenarios against EDGAR, and set EDGAR_RECORD=1 to
+//     record them as well. Ex: EDGAR_RECORD=1 go test -live .
+//     Scenarios that are neither recorded nor run live are skipped.
+
+var live = flag.Bool("live", false, "run the live scenarios against the EDGAR website")
+
+func liveFetcher(t *testing.T, scenario string) *fetcher {
+	dir := filepath.Join("testdata", "live", scenario)
+	if *live {
+		var opts []Option
+		if os.Getenv("EDGAR_RECORD") != "" {
+			opts = append(opts, WithTransport(NewRecordingTransport(dir, nil)))
+		}
+		if ua := os.Getenv("EDGAR_USER_AGENT"); ua != "" {
+			opts = append(opts, WithUserAgent(ua))
+		}
+		return NewFilingFetcher(opts...).(*fetcher)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip("No recording of the " + scenario + " scenario. Run the tests with -live to run it against EDGAR")
+	}
+	return NewFilingFetcher(WithTransport(NewReplayTransport(dir)), WithUserAgent(testUserAgent), WithRateLimit(0)).(*fetcher)
+}
 
 func SkipTestFolderWriter(t *testing.T) {
 	fetcher := NewFilingFetcher()
 	c, err := fetcher.CompanyFolder("AGN", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 	for _, val := range files {
@@ -645,10 +1142,10 @@ func SkipTestFolderWriter(t *testing.T) {
 
 func TestLiveMSFTParsing(t *testing.T) {
 	fmt.Println("*** Running a live MSFT test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "MSFT")
 	c, err := fetcher.CompanyFolder("MSFT", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 	for _, val := range files {
@@ -731,10 +1228,10 @@ func TestLiveMSFTParsing(t *testing.T) {
 
 func TestLiveMSFTParallel(t *testing.T) {
 	fmt.Println("*** Running a live MSFT parallel test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "MSFT-Parallel")
 	c, err := fetcher.CompanyFolder("MSFT", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 	filings, err := c.Filings(FilingType10K, files...)
@@ -817,10 +1314,10 @@ func TestLiveMSFTParallel(t *testing.T) {
 
 func TestLiveIBMParsing(t *testing.T) {
 	fmt.Println("*** Running a live IBM test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "IBM")
 	c, err := fetcher.CompanyFolder("IBM", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 
@@ -857,10 +1354,10 @@ func TestLiveIBMParsing(t *testing.T) {
 
 func TestLivePSXParsing(t *testing.T) {
 	fmt.Println("*** Running a live PSX test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "PSX")
 	c, err := fetcher.CompanyFolder("PSX", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 
@@ -888,10 +1385,10 @@ func TestLivePSXParsing(t *testing.T) {
 
 func TestLiveAMGNParsing(t *testing.T) {
 	fmt.Println("*** Running a live AMGN test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "AMGN")
 	c, err := fetcher.CompanyFolder("AMGN", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 
@@ -906,10 +1403,10 @@ func TestLiveAMGNParsing(t *testing.T) {
 
 func TestLiveCSCOParsing(t *testing.T) {
 	fmt.Println("*** Running a live CSCO test ***")
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "CSCO")
 	c, err := fetcher.CompanyFolder("CSCO", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 
@@ -930,10 +1427,10 @@ func TestLiveCSCOParsing(t *testing.T) {
 }
 
 func TestVariousExceptions(t *testing.T) {
-	fetcher := NewFilingFetcher()
+	fetcher := liveFetcher(t, "TGT")
 	c, err := fetcher.CompanyFolder("TGT", FilingType10K)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	files := c.AvailableFilings(FilingType10K)
 	for _, val := range files {
@@ -971,3 +1468,70 @@ func TestVariousExceptions(t *testing.T) {
 		}
 	}
 }
+
+func TestReportFacts(t *testing.T) {
+	f, _ := os.Open("samples/sample_ops.html")
+	var file filing
+	file.FinData = newFinancialReport(FilingType10Q)
+	_, err := finReportParser(f, file.FinData, filingDocOps)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Concepts that are not collected are kept for every column
+	end := time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC)
+	rd, err := file.Fact("us-gaap:ResearchAndDevelopmentExpense")
+	expected := Fact{"us-gaap:ResearchAndDevelopmentExpense", 3701000000, "USD", 1000000, end, 3, "Operations"}
+	if err != nil || rd != expected {
+		t.Error("Incorrect research and development expense ", rd, err)
+	}
+	count := 0
+	for _, fact := range file.Facts() {
+		if fact.Concept == "us-gaap:ResearchAndDevelopmentExpense" {
+			count++
+		}
+	}
+	if count != 4 {
+		t.Error("Expected a research and development fact for every column ", count)
+	}
+
+	eps, err := file.Fact("defref_us-gaap_EarningsPerShareBasic")
+	if err != nil || eps.Value != 2.36 || eps.Unit != "USD/shares" || eps.Scale != 1 {
+		t.Error("Incorrect basic earnings per share ", eps, err)
+	}
+	shares, err := file.Fact("us-gaap_WeightedAverageNumberOfSharesOutstandingBasic")
+	if err != nil || shares.Value != 4882167000 || shares.Unit != "shares" || shares.Scale != 1000 {
+		t.Error("Incorrect basic share count ", shares, err)
+	}
+	if _, err := file.Fact("us-gaap:Goodwill"); err == nil {
+		t.Error("Expected an error for a concept not in the filing")
+	}
+}
+
+func TestReportMappings(t *testing.T) {
+	m := &MetricMappings{
+		Metrics: []CustomMetric{{Name: "Research Expense"}},
+		Companies: map[string]map[string]Metric{
+			"AAPL": {"defref_us-gaap_ResearchAndDevelopmentExpense": "Research Expense"},
+		},
+	}
+	f, _ := os.Open("samples/sample_ops.html")
+	var file filing
+	file.FinData = newFinancialReport(FilingType10Q)
+	file.FinData.tags = m.metricTags("aapl")
+	_, err := finReportParser(f, file.FinData, filingDocOps)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The custom metric is scaled like the money of the report
+	if val, err := file.Value("Research Expense"); err != nil || val != 3701000000 {
+		t.Error("Incorrect research expense ", val, err)
+	}
+	if periods := file.PeriodValues("Research Expense"); len(periods) != 4 || periods[1].Value != 10486000000 {
+		t.Error("Incorrect research expense periods ", periods)
+	}
+	if _, ok := m.metricTags("MSFT").dataType("defref_us-gaap_ResearchAndDevelopmentExpense"); ok {
+		t.Error("Expected no mappings for other companies")
+	}
+}
@@ -0,0 +1,189 @@
+package edgar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// fourthQuarter makes the report of the fourth quarter of a fiscal year out
+// of the annual report and the reports of the first three quarters. The
+// flow data of the quarter is the data of the year less the data of the
+// three quarters and is marked as derived, as is the data of the custom
+// metrics. Share counts are taken from the fourth quarter of the annual
+// report and are left out when it does not report them. The balance sheet
+// and the entity information are as of the end of the year.
+func fourthQuarter(annual *financialReport, quarters []*financialReport) *financialReport {
+	fr := newFinancialReport(FilingType10Q)
+	*fr.Entity = *annual.Entity
+	*fr.Bs = *annual.Bs
+	fr.Entity.FiscalPeriod = "Q4"
+	end := annual.periodEnd()
+
+	for _, p := range annual.Periods {
+		if p.Months == 0 && time.Time(p.End).Equal(time.Time(end)) {
+			fr.Periods = append(fr.Periods, p)
+		}
+	}
+
+	derive := func(data interface{}, year interface{}, quarter func(*financialReport) interface{}) {
+		t := reflect.TypeOf(data).Elem()
+		v := reflect.ValueOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			entity, ok := t.Field(i).Tag.Lookup("entity")
+			if !ok {
+				continue
+			}
+			finType := finDataType(t.Field(i).Tag.Get("json"))
+			// Share counts are averages that can not be derived. They are
+			// kept when the annual report has them for the fourth quarter.
+			if scaleEntity(entity) == scaleEntityShares {
+				if q := annual.period(finType, end, 3); q >= 0 {
+					v.Field(i).SetFloat(annual.Periods[q].Value)
+					setCollectedData(data, i)
+					fr.Periods = append(fr.Periods, annual.Periods[q])
+				}
+				continue
+			}
+			if !isCollectedDataSet(year, t.Field(i).Name) {
+				continue
+			}
+			val := reflect.ValueOf(year).Elem().Field(i).Float()
+			collected := true
+			for _, q := range quarters {
+				if !isCollectedDataSet(quarter(q), t.Field(i).Name) {
+					collected = false
+					break
+				}
+				val -= reflect.ValueOf(quarter(q)).Elem().Field(i).Float()
+			}
+			if !collected {
+				continue
+			}
+			// Per share data have a few decimals
+			if scaleEntity(entity) == scaleEntityPerShare {
+				val = math.Round(val*10000) / 10000
+			}
+			v.Field(i).SetFloat(val)
+			setCollectedData(data, i)
+			fr.setDerived(finType, true)
+			fr.Periods = append(fr.Periods, periodValue{
+				Data:   finType,
+				End:    end,
+				Months: 3,
+				Value:  val,
+			})
+		}
+	}
+	derive(fr.Ops, annual.Ops, func(q *financialReport) interface{} { return q.Ops })
+	derive(fr.Cf, annual.Cf, func(q *financialReport) interface{} { return q.Cf })
+
+	// Custom metrics reported as of the end of the year are kept as is
+	for fin, entity := range annual.CustomUnits {
+		val, ok := annual.Custom[fin]
+		if !ok {
+			continue
+		}
+		fr.setCustomUnit(fin, entity)
+		if annual.period(fin, end, 0) >= 0 {
+			fr.setCustomValue(fin, val)
+			continue
+		}
+		if entity == scaleEntityShares {
+			if q := annual.period(fin, end, 3); q >= 0 {
+				fr.setCustomValue(fin, annual.Periods[q].Value)
+				fr.Periods = append(fr.Periods, annual.Periods[q])
+			}
+			continue
+		}
+		collected := true
+		for _, q := range quarters {
+			qVal, ok := q.Custom[fin]
+			if !ok {
+				collected = false
+				break
+			}
+			val -= qVal
+		}
+		if !collected {
+			continue
+		}
+		if entity == scaleEntityPerShare {
+			val = math.Round(val*10000) / 10000
+		}
+		fr.setCustomValue(fin, val)
+		fr.setDerived(fin, true)
+		fr.Periods = append(fr.Periods, periodValue{
+			Data:   fin,
+			End:    end,
+			Months: 3,
+			Value:  val,
+		})
+	}
+	return fr
+}
+
+// fiscalQuarters gets the quarterly filings for the first three quarters
+// of the fiscal year ending on the given date. The quarters end in the ten
+// months before the end of the year.
+func (c *company) fiscalQuarters(ctx context.Context, end time.Time, filed time.Time) ([]*filing, error) {
+	var dates []time.Time
+	for _, d := range c.AvailableFilings(FilingType10Q) {
+		if d.After(end.AddDate(-1, 0, 0)) && d.Before(filed) {
+			dates = append(dates, d)
+		}
+	}
+	files, err := c.FilingsContext(ctx, FilingType10Q, dates...)
+	if err != nil {
+		return nil, err
+	}
+	quarters := make(map[string]*filing)
+	for _, f := range files {
+		file := f.(*filing)
+		qEnd := time.Time(file.FinData.periodEnd())
+		if qEnd.After(end.AddDate(0, -10, 0)) && qEnd.Before(end) {
+			quarters[getDateString(qEnd)] = file
+		}
+	}
+	if len(quarters) != 3 {
+		return nil, fmt.Errorf("Found %d of the 3 quarterly filings of the fiscal year ending on %s",
+			len(quarters), getDateString(end))
+	}
+	var ret []*filing
+	for _, file := range quarters {
+		ret = append(ret, file)
+	}
+	return ret, nil
+}
+
+func (c *company) FourthQuarter(ts time.Time) (Filing, error) {
+	return c.FourthQuarterContext(context.Background(), ts)
+}
+
+func (c *company) FourthQuarterContext(ctx context.Context, ts time.Time) (Filing, error) {
+	f, err := c.FilingContext(ctx, FilingType10K, ts)
+	if err != nil {
+		return nil, err
+	}
+	annual := f.(*filing)
+	end := time.Time(annual.FinData.periodEnd())
+	if end.IsZero() {
+		return nil, errors.New("Could not find the end of the fiscal year of the filing on " + getDateString(ts))
+	}
+	quarters, err := c.fiscalQuarters(ctx, end, ts)
+	if err != nil {
+		return nil, err
+	}
+	var reports []*financialReport
+	for _, q := range quarters {
+		reports = append(reports, q.FinData)
+	}
+	return &filing{
+		Company: annual.Company,
+		Date:    annual.Date,
+		FinData: fourthQuarter(annual.FinData, reports),
+	}, nil
+}
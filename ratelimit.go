@@ -0,0 +1,68 @@
+package edgar
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultRateLimit is the maximum number of requests per second allowed
+// by the SEC fair access policy
+const defaultRateLimit = 10
+
+// rateLimiter is a token bucket shared by all the requests made by a fetcher.
+// The bucket holds at most burst tokens and is refilled at rate tokens per
+// second. Every request takes a token and waits if the bucket is empty.
+type rateLimiter struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller
+// needs to wait before the token can be used
+func (r *rateLimiter) reserve() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// wait blocks till the caller is allowed to make a request or till
+// the context is done
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r == nil {
+		return ctx.Err()
+	}
+	if d := r.reserve(); d > 0 {
+		return sleep(ctx, d)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,115 @@
+package edgar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// recordedResponse is the metadata stored for every recorded request.
+// The body of the response is stored next to it in a file of its own.
+type recordedResponse struct {
+	URL        string      `json:"URL"`
+	StatusCode int         `json:"Status"`
+	Header     http.Header `json:"Header"`
+}
+
+// recordKey identifies a request in a recording. The host is left out of
+// the key so that a recording can be replayed against any base URL.
+func recordKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.RequestURI()))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecordingTransport creates a transport that makes every request using
+// the next transport and records the response into the given directory.
+// The recording can be replayed using a transport from NewReplayTransport.
+func NewRecordingTransport(dir string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{dir: dir, next: next}
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	meta, err := json.MarshalIndent(recordedResponse{
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, err
+	}
+	key := filepath.Join(r.dir, recordKey(req))
+	if err := ioutil.WriteFile(key+".body", b, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(key+".json", meta, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayTransport struct {
+	dir string
+}
+
+// NewReplayTransport creates a transport that serves every request from
+// a recording made with a transport from NewRecordingTransport. Requests
+// that are not in the recording fail without going to the network.
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+func (r *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := filepath.Join(r.dir, recordKey(req))
+	meta, err := ioutil.ReadFile(key + ".json")
+	if err != nil {
+		return nil, errors.New("No recorded response for " + req.URL.String())
+	}
+	var rec recordedResponse
+	if err := json.Unmarshal(meta, &rec); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(key + ".body")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
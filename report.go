@@ -2,20 +2,65 @@ package edgar
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type financialReport struct {
-	DocType FilingType  `json:"Filing Type"`
-	Entity  *entityData `json:"Entity Information"`
-	Ops     *opsData    `json:"Operational Information"`
-	Bs      *bsData     `json:"Balance Sheet Information"`
-	Cf      *cfData     `json:"Cash Flow Information"`
+	DocType FilingType    `json:"Filing Type"`
+	Entity  *entityData   `json:"Entity Information"`
+	Ops     *opsData      `json:"Operational Information"`
+	Bs      *bsData       `json:"Balance Sheet Information"`
+	Cf      *cfData       `json:"Cash Flow Information"`
+	Periods []periodValue `json:"Periods,omitempty"`
+	Derived []finDataType `json:"Derived,omitempty"`
+	Facts   []factValue   `json:"Facts,omitempty"`
+	// Custom is the data of the custom metrics of the mappings of the company
+	// and CustomUnits the scale entities of the custom metrics reported
+	Custom      map[finDataType]float64     `json:"Custom Data,omitempty"`
+	CustomUnits map[finDataType]scaleEntity `json:"Custom Units,omitempty"`
+	tags        *metricTags
+	// taxonomy is the taxonomy the filing is made with. It is detected once
+	// for all the reports of the filing.
+	taxonomy taxonomy
+}
+
+// periodValue is the value of the data for a period reported in a filing.
+// Statements report the prior periods next to the period of the filing.
+// Months is 0 for values as of an instant and when the duration of the
+// period is not reported.
+type periodValue struct {
+	Data   finDataType `json:"Data"`
+	End    Timestamp   `json:"Period end"`
+	Months int         `json:"Months,omitempty"`
+	Value  float64     `json:"Value"`
+}
+
+// factValue is the value of an XBRL concept reported in a filing, whether
+// or not the concept is collected in the data of the report. The value is
+// in units and Scale is the factor that it was presented in. Report is the
+// report of the filing that the value is read from.
+type factValue struct {
+	Concept string    `json:"Concept"`
+	Value   float64   `json:"Value"`
+	Unit    string    `json:"Unit"`
+	Scale   float64   `json:"Scale,omitempty"`
+	End     Timestamp `json:"Period end"`
+	Months  int       `json:"Months,omitempty"`
+	Report  string    `json:"Report,omitempty"`
 }
 
 type entityData struct {
-	CollectedData uint64  `json:"Collected Data"`
-	ShareCount    float64 `json:"Shares Outstanding" required:"true" entity:"Shares" bit:"0"`
+	CollectedData uint64     `json:"Collected Data"`
+	ShareCount    float64    `json:"Shares Outstanding" required:"true" entity:"Shares" bit:"0"`
+	PeriodEnd     *Timestamp `json:"Period End,omitempty"`
+	FiscalYear    int        `json:"Fiscal Year,omitempty"`
+	FiscalPeriod  string     `json:"Fiscal Period,omitempty"`
+	FiscalYearEnd string     `json:"Fiscal Year End,omitempty"`
 }
 
 type opsData struct {
@@ -96,3 +141,433 @@ func (ops opsData) String() string {
 	}
 	return string(data)
 }
+
+// mergeFinancialReport overrides the data in the report with the data
+// collected in the other report. Data not collected in the other report
+// is left as is.
+func mergeFinancialReport(fr *financialReport, other *financialReport) {
+	merge := func(data interface{}, from interface{}) {
+		if reflect.ValueOf(from).IsNil() {
+			return
+		}
+		t := reflect.TypeOf(data).Elem()
+		v := reflect.ValueOf(data).Elem()
+		vf := reflect.ValueOf(from).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Type.Kind() != reflect.Float64 {
+				continue
+			}
+			if isCollectedDataSet(from, t.Field(i).Name) {
+				v.Field(i).SetFloat(vf.Field(i).Float())
+				setCollectedData(data, i)
+				finType := finDataType(t.Field(i).Tag.Get("json"))
+				fr.setDerived(finType, other.isDerived(finType))
+			}
+		}
+	}
+	merge(fr.Entity, other.Entity)
+	if fr.Entity != nil && other.Entity != nil {
+		mergeDocumentInfo(fr.Entity, other.Entity)
+	}
+	merge(fr.Ops, other.Ops)
+	merge(fr.Bs, other.Bs)
+	merge(fr.Cf, other.Cf)
+	for fin, val := range other.Custom {
+		fr.setCustomValue(fin, val)
+	}
+	for fin, entity := range other.CustomUnits {
+		fr.setCustomUnit(fin, entity)
+	}
+	for _, p := range other.Periods {
+		if i := fr.period(p.Data, p.End, p.Months); i >= 0 {
+			fr.Periods[i] = p
+		} else {
+			fr.Periods = append(fr.Periods, p)
+		}
+	}
+	for _, f := range other.Facts {
+		if i := fr.fact(f); i >= 0 {
+			fr.Facts[i] = f
+		} else {
+			fr.Facts = append(fr.Facts, f)
+		}
+	}
+}
+
+// dataType gets the metric that a tag is collected as. The mappings of the
+// company are looked up before the tags of the taxonomy.
+func (fr *financialReport) dataType(key string) finDataType {
+	if fin, ok := fr.tags.dataType(key); ok {
+		return fin
+	}
+	return getFinDataTypeFromXBRLTag(key, fr.taxonomy)
+}
+
+// metricEntity gets the scale entity of a metric of the package or of a
+// custom metric of the company
+func (fr *financialReport) metricEntity(fin finDataType) (scaleEntity, bool) {
+	if _, entity, ok := metricField(fin); ok {
+		return entity, true
+	}
+	if entity, ok := fr.CustomUnits[fin]; ok {
+		return entity, true
+	}
+	return fr.tags.customEntity(fin)
+}
+
+// setCustomUnit records the scale entity of a custom metric reported in
+// the report, which tells how the metric is derived after the mappings of
+// the company are gone
+func (fr *financialReport) setCustomUnit(fin finDataType, entity scaleEntity) {
+	if fr.CustomUnits == nil {
+		fr.CustomUnits = make(map[finDataType]scaleEntity)
+	}
+	fr.CustomUnits[fin] = entity
+}
+
+// setCustomValue sets the data of a custom metric
+func (fr *financialReport) setCustomValue(fin finDataType, val float64) {
+	if fr.Custom == nil {
+		fr.Custom = make(map[finDataType]float64)
+	}
+	fr.Custom[fin] = val
+}
+
+// setCustom sets the data of a custom metric. The first value set is kept.
+func (fr *financialReport) setCustom(fin finDataType, val string, scale map[scaleEntity]scaleFactor) error {
+	entity, ok := fr.tags.customEntity(fin)
+	if !ok {
+		return errors.New("Could not find the field to set: " + string(fin))
+	}
+	if _, ok := fr.Custom[fin]; ok {
+		return nil
+	}
+	num, err := normalizeNumber(val)
+	if err != nil {
+		return err
+	}
+	if factor, ok := scale[entity]; ok {
+		num *= float64(factor)
+	}
+	fr.setCustomValue(fin, num)
+	fr.setCustomUnit(fin, entity)
+	return nil
+}
+
+// value gets the collected data of a metric of the package or of a custom
+// metric
+func (fr *financialReport) value(fin finDataType) (float64, bool) {
+	if val, ok := fr.Custom[fin]; ok {
+		return val, true
+	}
+	for _, data := range []interface{}{fr.Entity, fr.Ops, fr.Bs, fr.Cf} {
+		if reflect.ValueOf(data).IsNil() {
+			continue
+		}
+		t := reflect.TypeOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("json") == string(fin) && isCollectedDataSet(data, t.Field(i).Name) {
+				return reflect.ValueOf(data).Elem().Field(i).Float(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// fact finds the value of a concept for the period of a fact read out of
+// the same report. It is -1 if the report has no such value.
+func (fr *financialReport) fact(f factValue) int {
+	for i, v := range fr.Facts {
+		if v.Concept == f.Concept && v.Report == f.Report && v.Months == f.Months &&
+			time.Time(v.End).Equal(time.Time(f.End)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// addFact adds the value of a concept to the report. The first value read
+// for a period is kept.
+func (fr *financialReport) addFact(f factValue) {
+	if fr.fact(f) < 0 {
+		fr.Facts = append(fr.Facts, f)
+	}
+}
+
+// period finds the value of the data for a period. It is -1 if the report
+// has no value for the period.
+func (fr *financialReport) period(finType finDataType, end Timestamp, months int) int {
+	for i, p := range fr.Periods {
+		if p.Data == finType && time.Time(p.End).Equal(time.Time(end)) && p.Months == months {
+			return i
+		}
+	}
+	return -1
+}
+
+// addPeriod adds the value of the data for a period. The value is scaled
+// like the data of the report. The first value found for a period is kept.
+func (fr *financialReport) addPeriod(
+	finType finDataType,
+	end Timestamp,
+	months int,
+	val string,
+	scale map[scaleEntity]scaleFactor, t filingDocType) error {
+
+	if fileType, ok := strictDataToDocMap[finType]; ok && t != fileType {
+		return nil
+	}
+	entity, ok := fr.metricEntity(finType)
+	if !ok {
+		return errors.New("Could not find the field to set: " + string(finType))
+	}
+	num, err := normalizeNumber(val)
+	if err != nil {
+		return err
+	}
+	if factor, ok := scale[entity]; ok {
+		num *= float64(factor)
+	}
+	if fr.period(finType, end, months) < 0 {
+		fr.Periods = append(fr.Periods, periodValue{
+			Data:   finType,
+			End:    end,
+			Months: months,
+			Value:  num,
+		})
+	}
+	if _, _, ok := metricField(finType); !ok {
+		fr.setCustomUnit(finType, entity)
+	}
+	return nil
+}
+
+// setDocumentInfo sets the information of the document of the filing
+// reported in the entity information. The first value reported is kept.
+// The dates are reported as in the viewer or as in the XBRL facts.
+// Ex: Jun. 30, 2018 or 2018-06-30
+func setDocumentInfo(en *entityData, key string, val string) bool {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return false
+	}
+	switch key {
+	case "defref_dei_DocumentPeriodEndDate":
+		if en.PeriodEnd == nil {
+			end := reportDate(val)
+			if getYear(val) > 0 {
+				end = getDate(val)
+			}
+			if time.Time(end).IsZero() {
+				return false
+			}
+			en.PeriodEnd = &end
+		}
+	case "defref_dei_DocumentFiscalYearFocus":
+		if en.FiscalYear == 0 {
+			year, err := strconv.Atoi(val)
+			if err != nil {
+				return false
+			}
+			en.FiscalYear = year
+		}
+	case "defref_dei_DocumentFiscalPeriodFocus":
+		if en.FiscalPeriod == "" {
+			en.FiscalPeriod = strings.ToUpper(val)
+		}
+	case "defref_dei_CurrentFiscalYearEndDate":
+		if en.FiscalYearEnd == "" {
+			en.FiscalYearEnd = val
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// mergeDocumentInfo overrides the information of the document with the
+// information reported in the other report
+func mergeDocumentInfo(en *entityData, other *entityData) {
+	if other.PeriodEnd != nil {
+		en.PeriodEnd = other.PeriodEnd
+	}
+	if other.FiscalYear != 0 {
+		en.FiscalYear = other.FiscalYear
+	}
+	if other.FiscalPeriod != "" {
+		en.FiscalPeriod = other.FiscalPeriod
+	}
+	if other.FiscalYearEnd != "" {
+		en.FiscalYearEnd = other.FiscalYearEnd
+	}
+}
+
+func (fr *financialReport) isDerived(finType finDataType) bool {
+	for _, d := range fr.Derived {
+		if d == finType {
+			return true
+		}
+	}
+	return false
+}
+
+// setDerived marks the data as derived from other data of the filings
+// instead of being reported in the filing
+func (fr *financialReport) setDerived(finType finDataType, derived bool) {
+	for i, d := range fr.Derived {
+		if d == finType {
+			if !derived {
+				fr.Derived = append(fr.Derived[:i], fr.Derived[i+1:]...)
+			}
+			return
+		}
+	}
+	if derived {
+		fr.Derived = append(fr.Derived, finType)
+	}
+}
+
+// periodEnd gets the end of the period of the report. When the filing does
+// not report it the latest period of the statements is taken.
+func (fr *financialReport) periodEnd() Timestamp {
+	if fr.Entity != nil && fr.Entity.PeriodEnd != nil {
+		return *fr.Entity.PeriodEnd
+	}
+	var end Timestamp
+	for _, p := range fr.Periods {
+		if p.Months > 0 && time.Time(p.End).After(time.Time(end)) {
+			end = p.End
+		}
+	}
+	return end
+}
+
+// yearToDate finds the value of the data for the year to date of a
+// quarterly report when the quarter itself is not reported. It is -1 when
+// the quarter is reported or there is no value for the year to date.
+func (fr *financialReport) yearToDate(finType finDataType, end Timestamp) int {
+	ytd := -1
+	for i, p := range fr.Periods {
+		if p.Data != finType || !time.Time(p.End).Equal(time.Time(end)) {
+			continue
+		}
+		if p.Months == 3 {
+			return -1
+		}
+		if p.Months > 3 && (ytd < 0 || p.Months < fr.Periods[ytd].Months) {
+			ytd = i
+		}
+	}
+	return ytd
+}
+
+// quarterly makes the flow data of a quarterly report the data of the
+// quarter. Statements of a quarter report the quarter and the year to date.
+// Money reported only for the year to date is left out to be derived from
+// the year to date of the previous quarter. Share counts are averages and
+// are kept as reported.
+func (fr *financialReport) quarterly() {
+	end := fr.periodEnd()
+	if time.Time(end).IsZero() {
+		return
+	}
+	for _, data := range []interface{}{fr.Ops, fr.Cf} {
+		t := reflect.TypeOf(data).Elem()
+		v := reflect.ValueOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			entity, ok := t.Field(i).Tag.Lookup("entity")
+			if !ok {
+				continue
+			}
+			finType := finDataType(t.Field(i).Tag.Get("json"))
+			if q := fr.period(finType, end, 3); q >= 0 {
+				v.Field(i).SetFloat(fr.Periods[q].Value)
+				setCollectedData(data, i)
+			} else if fr.yearToDate(finType, end) >= 0 && scaleEntity(entity) != scaleEntityShares {
+				v.Field(i).SetFloat(0)
+				clearCollectedData(data, i)
+			}
+		}
+	}
+	for fin, entity := range fr.CustomUnits {
+		if q := fr.period(fin, end, 3); q >= 0 {
+			fr.setCustomValue(fin, fr.Periods[q].Value)
+		} else if fr.yearToDate(fin, end) >= 0 && entity != scaleEntityShares {
+			delete(fr.Custom, fin)
+		}
+	}
+}
+
+// quarterMissing tells if there is flow data of a quarterly report that is
+// only reported for the year to date
+func (fr *financialReport) quarterMissing() bool {
+	end := fr.periodEnd()
+	for _, data := range []interface{}{fr.Ops, fr.Cf} {
+		t := reflect.TypeOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			finType := finDataType(t.Field(i).Tag.Get("json"))
+			if !isCollectedDataSet(data, t.Field(i).Name) && fr.yearToDate(finType, end) >= 0 {
+				return true
+			}
+		}
+	}
+	for fin := range fr.CustomUnits {
+		if _, ok := fr.Custom[fin]; !ok && fr.yearToDate(fin, end) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveQuarter derives the flow data of a quarterly report that is only
+// reported for the year to date as the difference with the year to date
+// reported in the previous quarterly report
+func (fr *financialReport) deriveQuarter(prev *financialReport) {
+	for _, data := range []interface{}{fr.Ops, fr.Cf} {
+		t := reflect.TypeOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if isCollectedDataSet(data, t.Field(i).Name) {
+				continue
+			}
+			finType := finDataType(t.Field(i).Tag.Get("json"))
+			val, ok := fr.quarterValue(finType, prev)
+			if !ok {
+				continue
+			}
+			if setData(fr, finType, strconv.FormatFloat(val, 'f', -1, 64), xbrlScale, filingDocIg) == nil {
+				fr.setDerived(finType, true)
+			}
+		}
+	}
+	for fin := range fr.CustomUnits {
+		if _, ok := fr.Custom[fin]; ok {
+			continue
+		}
+		if val, ok := fr.quarterValue(fin, prev); ok {
+			fr.setCustomValue(fin, val)
+			fr.setDerived(fin, true)
+		}
+	}
+}
+
+// quarterValue gets the value of the data for the quarter of a quarterly
+// report out of the year to date of the report and the year to date of the
+// previous quarterly report
+func (fr *financialReport) quarterValue(finType finDataType, prev *financialReport) (float64, bool) {
+	end := fr.periodEnd()
+	ytd := fr.yearToDate(finType, end)
+	if ytd < 0 {
+		return 0, false
+	}
+	// The year to date of the previous quarter ends about a quarter
+	// before the year to date of the report
+	cur := fr.Periods[ytd]
+	from, to := time.Time(end).AddDate(0, -4, 0), time.Time(end).AddDate(0, -2, 0)
+	for _, p := range prev.Periods {
+		if p.Data == finType && p.Months == cur.Months-3 &&
+			time.Time(p.End).After(from) && time.Time(p.End).Before(to) {
+			return cur.Value - p.Value, true
+		}
+	}
+	return 0, false
+}
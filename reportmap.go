@@ -60,7 +60,10 @@ func lookupDocType(data string, menu menuCat) filingDocType {
 		} else if strings.Contains(data, "EARNINGS") {
 			//Income statement
 			return filingDocInc
-		} else if strings.Contains(data, "CASH FLOWS") {
+		} else if strings.Contains(data, "PROFIT OR LOSS") {
+			//IFRS income statement
+			return filingDocInc
+		} else if strings.Contains(data, "CASH FLOW") {
 			//Cash flow statement
 			return filingDocCF
 		}
@@ -134,7 +137,7 @@ loop:
 					}
 					token = z.Token()
 					docType := lookupDocType(token.String(), menuCategory)
-					if docType != filingDocIg {
+					if docType != filingDocIg && reportNum > 0 && reportNum <= len(filingLinks) {
 						//Get the report number
 						_, ok := retData[docType]
 						if !ok {
@@ -0,0 +1,198 @@
+package edgar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompanyInfo identifies a company filing with the SEC
+type CompanyInfo struct {
+	CIK      string
+	Ticker   string
+	Name     string
+	Exchange string
+}
+
+// CIKResolver looks up companies by their ticker, CIK or name without
+// going to EDGAR. A fetcher with a resolver goes to EDGAR only for the
+// tickers that the resolver does not know about.
+type CIKResolver interface {
+
+	// LookupTicker gets the company with the given ticker
+	LookupTicker(ticker string) (CompanyInfo, error)
+
+	// LookupCIK gets the company with the given CIK. The CIK can be
+	// with or without the leading zeros
+	LookupCIK(cik string) (CompanyInfo, error)
+
+	// LookupName gets the companies with the given name. A company whose
+	// name matches exactly (ignoring case) is the only one returned.
+	// Otherwise all the companies whose name contains the given name are
+	// returned.
+	LookupName(name string) ([]CompanyInfo, error)
+}
+
+type tickerResolver struct {
+	byTicker map[string]CompanyInfo
+	byCIK    map[string]CompanyInfo
+	all      []CompanyInfo
+}
+
+// formatCIK formats a CIK the way EDGAR does, as 10 digits with leading zeros
+func formatCIK(cik string) string {
+	cik = strings.TrimLeft(strings.TrimSpace(cik), "0")
+	if len(cik) >= 10 {
+		return cik
+	}
+	return strings.Repeat("0", 10-len(cik)) + cik
+}
+
+// NewTickerResolver creates a resolver out of the company tickers files
+// published by the SEC. Both company_tickers.json and
+// company_tickers_exchange.json are understood. The exchange is known
+// only with the latter.
+func NewTickerResolver(r io.Reader) (CIKResolver, error) {
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	res := &tickerResolver{
+		byTicker: make(map[string]CompanyInfo),
+		byCIK:    make(map[string]CompanyInfo),
+	}
+
+	fields, hasFields := doc["fields"]
+	data, hasData := doc["data"]
+	if hasFields && hasData {
+		// company_tickers_exchange.json is a table of fields and rows
+		var names []string
+		var rows [][]interface{}
+		if err := json.Unmarshal(fields, &names); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			var info CompanyInfo
+			for i, val := range row {
+				if i >= len(names) || val == nil {
+					continue
+				}
+				str := fmt.Sprint(val)
+				if num, ok := val.(float64); ok {
+					str = fmt.Sprintf("%.0f", num)
+				}
+				switch names[i] {
+				case "cik":
+					info.CIK = formatCIK(str)
+				case "name":
+					info.Name = str
+				case "ticker":
+					info.Ticker = str
+				case "exchange":
+					info.Exchange = str
+				}
+			}
+			res.add(info)
+		}
+	} else {
+		// company_tickers.json is a list of companies keyed by a row number
+		keys := make([]string, 0, len(doc))
+		for key := range doc {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+		for _, key := range keys {
+			val := doc[key]
+			var entry struct {
+				CIK    json.Number `json:"cik_str"`
+				Ticker string      `json:"ticker"`
+				Title  string      `json:"title"`
+			}
+			if err := json.Unmarshal(val, &entry); err != nil {
+				return nil, err
+			}
+			res.add(CompanyInfo{
+				CIK:    formatCIK(entry.CIK.String()),
+				Ticker: entry.Ticker,
+				Name:   entry.Title,
+			})
+		}
+	}
+	if len(res.all) == 0 {
+		return nil, errors.New("No companies found in the company tickers file")
+	}
+	sort.SliceStable(res.all, func(i, j int) bool {
+		return res.all[i].Name < res.all[j].Name
+	})
+	return res, nil
+}
+
+// LoadTickerResolver creates a resolver out of a company tickers file on disk
+func LoadTickerResolver(path string) (CIKResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewTickerResolver(f)
+}
+
+func (r *tickerResolver) add(info CompanyInfo) {
+	if info.CIK == "" || info.Ticker == "" {
+		return
+	}
+	r.byTicker[strings.ToUpper(info.Ticker)] = info
+	// A company with many tickers is looked up by CIK as its first ticker
+	// which is the primary one in the files published by the SEC
+	if _, ok := r.byCIK[info.CIK]; !ok {
+		r.byCIK[info.CIK] = info
+	}
+	r.all = append(r.all, info)
+}
+
+func (r *tickerResolver) LookupTicker(ticker string) (CompanyInfo, error) {
+	info, ok := r.byTicker[strings.ToUpper(strings.TrimSpace(ticker))]
+	if !ok {
+		return info, errors.New("Could not find the CIK for the ticker " + ticker)
+	}
+	return info, nil
+}
+
+func (r *tickerResolver) LookupCIK(cik string) (CompanyInfo, error) {
+	info, ok := r.byCIK[formatCIK(cik)]
+	if !ok {
+		return info, errors.New("Could not find the company for the CIK " + cik)
+	}
+	return info, nil
+}
+
+func (r *tickerResolver) LookupName(name string) ([]CompanyInfo, error) {
+	var ret []CompanyInfo
+	seen := make(map[string]bool)
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, info := range r.all {
+		if strings.ToLower(info.Name) == name {
+			return []CompanyInfo{r.byCIK[info.CIK]}, nil
+		}
+		if strings.Contains(strings.ToLower(info.Name), name) && !seen[info.CIK] {
+			seen[info.CIK] = true
+			ret = append(ret, r.byCIK[info.CIK])
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("Could not find a company named " + name)
+	}
+	return ret, nil
+}
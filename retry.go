@@ -0,0 +1,82 @@
+package edgar
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultRetries    = 3
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// retryPolicy decides if and when a failed request is attempted again
+type retryPolicy struct {
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func newRetryPolicy() retryPolicy {
+	return retryPolicy{
+		retries:    defaultRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+}
+
+// retryable returns true for errors that are worth another attempt.
+// Throttling and server side failures are usually transient.
+func (p retryPolicy) retryable(err error, attempt int) bool {
+	if attempt >= p.retries {
+		return false
+	}
+	switch err.(type) {
+	case *RateLimitedError, *ServerError:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before the next attempt. The wait grows
+// exponentially with every attempt and is jittered so that parallel
+// fetches do not retry in lock step. A Retry-After sent by EDGAR takes
+// precedence if it asks for a longer wait.
+func (p retryPolicy) backoff(err error, attempt int) time.Duration {
+	d := p.minBackoff << uint(attempt)
+	if d <= 0 || d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	if e, ok := err.(*RateLimitedError); ok && e.RetryAfter > d {
+		d = e.RetryAfter
+	}
+	return d
+}
+
+// parseRetryAfter parses the Retry-After header that is either a number
+// of seconds or an HTTP date
+func parseRetryAfter(val string) time.Duration {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
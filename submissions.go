@@ -0,0 +1,169 @@
+package edgar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Discovery is the backend used to discover the filings of a company
+type Discovery int
+
+const (
+	// DiscoverQueryPage discovers filings by crawling the company search
+	// pages of EDGAR
+	DiscoverQueryPage Discovery = iota
+
+	// DiscoverSubmissions discovers filings using the submissions API of
+	// EDGAR. The API has more information about every filing, like the
+	// period of the report and the primary document of the filing.
+	DiscoverSubmissions
+)
+
+var (
+	submissionsURL = "submissions/CIK%010d.json"
+	historyURL     = "submissions/%s"
+	viewerURL      = "/cgi-bin/viewer?action=view&cik=%d&accession_number=%s&xbrl_type=v"
+)
+
+// submissionFilings is the column oriented list of filings in a submissions
+// document. The n'th filing is made of the n'th entry of every column.
+type submissionFilings struct {
+	AccessionNumber []string `json:"accessionNumber"`
+	FilingDate      []string `json:"filingDate"`
+	ReportDate      []string `json:"reportDate"`
+	Form            []string `json:"form"`
+	PrimaryDocument []string `json:"primaryDocument"`
+	IsXBRL          []int    `json:"isXBRL"`
+	IsInlineXBRL    []int    `json:"isInlineXBRL"`
+}
+
+// submissionFile is a file with older filings of the company
+type submissionFile struct {
+	Name       string `json:"name"`
+	FilingFrom string `json:"filingFrom"`
+	FilingTo   string `json:"filingTo"`
+}
+
+// submissions is the submissions document of a company. Recent has the
+// latest filings and the older filings are paged into the history files.
+type submissions struct {
+	CIK     string   `json:"cik"`
+	Name    string   `json:"name"`
+	Tickers []string `json:"tickers"`
+	Filings struct {
+		Recent submissionFilings `json:"recent"`
+		Files  []submissionFile  `json:"files"`
+	} `json:"filings"`
+}
+
+func submissionsParser(page io.Reader) (*submissions, error) {
+	s := new(submissions)
+	if err := json.NewDecoder(page).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func submissionHistoryParser(page io.Reader) (*submissionFilings, error) {
+	s := new(submissionFilings)
+	if err := json.NewDecoder(page).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func column(col []string, i int) string {
+	if i < len(col) {
+		return col[i]
+	}
+	return ""
+}
+
+func submissionFlag(col []int, i int) bool {
+	return i < len(col) && col[i] != 0
+}
+
+// addLinks adds the filings of the wanted types filed in or after the
+// threshold year to the links
+func (s *submissionFilings) addLinks(
+	links map[FilingType]map[string]filingLink,
+	cik int,
+	threshold int) {
+
+	for i, an := range s.AccessionNumber {
+		form := FilingType(column(s.Form, i))
+		l, ok := links[form]
+		if !ok {
+			continue
+		}
+		filed := column(s.FilingDate, i)
+		if getYear(filed) < threshold {
+			continue
+		}
+		l[filed] = filingLink{
+			Link:            fmt.Sprintf(viewerURL, cik, an),
+			Accession:       an,
+			Form:            string(form),
+			FilingDate:      filed,
+			ReportDate:      column(s.ReportDate, i),
+			PrimaryDocument: column(s.PrimaryDocument, i),
+			IsXBRL:          submissionFlag(s.IsXBRL, i),
+			IsInlineXBRL:    submissionFlag(s.IsInlineXBRL, i),
+		}
+	}
+}
+
+// dataURL creates an absolute URL out of a path relative to the EDGAR API site
+func (c *client) dataPageURL(path string) string {
+	return strings.TrimRight(c.dataURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// getSubmissionLinks gets the links for filings of the given types from
+// the submissions of the company. The history files are read only if they
+// have filings filed in or after the threshold year.
+func (c *client) getSubmissionLinks(
+	ctx context.Context,
+	cik string,
+	fileTypes ...FilingType) (map[FilingType]map[string]filingLink, error) {
+
+	num, err := strconv.Atoi(strings.TrimLeft(cik, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CIK %s: %w", cik, err)
+	}
+	links := make(map[FilingType]map[string]filingLink)
+	for _, t := range fileTypes {
+		links[t] = make(map[string]filingLink)
+	}
+
+	page, err := c.getPage(ctx, c.dataPageURL(fmt.Sprintf(submissionsURL, num)))
+	if err != nil {
+		return nil, err
+	}
+	s, err := submissionsParser(page)
+	page.Close()
+	if err != nil {
+		return nil, err
+	}
+	s.Filings.Recent.addLinks(links, num, c.threshold)
+
+	for _, file := range s.Filings.Files {
+		if getYear(file.FilingTo) < c.threshold {
+			continue
+		}
+		page, err := c.getPage(ctx, c.dataPageURL(fmt.Sprintf(historyURL, file.Name)))
+		if err != nil {
+			return nil, err
+		}
+		h, err := submissionHistoryParser(page)
+		page.Close()
+		if err != nil {
+			return nil, err
+		}
+		h.addLinks(links, num, c.threshold)
+	}
+	return links, nil
+}
@@ -0,0 +1,189 @@
+package edgar
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var summaryURL = "Archives/edgar/data/%s/%s/FilingSummary.xml"
+
+// summaryReport is a report listed in the FilingSummary.xml of a filing
+type summaryReport struct {
+	HTMLFileName string `xml:"HtmlFileName"`
+	XMLFileName  string `xml:"XmlFileName"`
+	LongName     string `xml:"LongName"`
+	ShortName    string `xml:"ShortName"`
+	MenuCategory string `xml:"MenuCategory"`
+	Position     int    `xml:"Position"`
+	Role         string `xml:"Role"`
+}
+
+// Keywords of the role URIs of the reports. The first keyword found in the
+// role identifies the report.
+var (
+	roleStatements = []struct {
+		key string
+		doc filingDocType
+	}{
+		{"parenthetical", filingDocIg},
+		{"balancesheet", filingDocBS},
+		{"financialposition", filingDocBS},
+		{"financialcondition", filingDocBS},
+		{"cashflow", filingDocCF},
+		{"operations", filingDocOps},
+		{"profitorloss", filingDocInc},
+		{"earnings", filingDocInc},
+		{"income", filingDocInc},
+	}
+
+	roleNotes = []struct {
+		key string
+		doc filingDocType
+	}{
+		{"earningspershare", filingDocEPSNotes},
+		{"netincomepershare", filingDocEPSNotes},
+		{"shareholdersequity", filingDocEquity},
+		{"stockholdersequity", filingDocEquity},
+		{"debt", filingDocDebt},
+	}
+)
+
+// fileName gets the name of the R page of the report. Older filings list
+// the reports as xml files.
+func (r summaryReport) fileName() string {
+	if r.HTMLFileName != "" {
+		return r.HTMLFileName
+	}
+	return strings.TrimSuffix(r.XMLFileName, ".xml") + ".htm"
+}
+
+// menuCategory gets the category of the report in the menu of the viewer.
+// Filings without menu categories are categorised by the kind of the report
+// in the long name. Ex: 0002 - Statement - CONSOLIDATED BALANCE SHEETS
+func (r summaryReport) menuCategory() menuCat {
+	switch strings.ToLower(r.MenuCategory) {
+	case "cover":
+		return menuCatCover
+	case "statements":
+		return menuCatFS
+	case "notes":
+		return menuCatNFS
+	case "":
+		parts := strings.SplitN(r.LongName, " - ", 3)
+		if len(parts) != 3 {
+			break
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[1])) {
+		case "document":
+			return menuCatCover
+		case "statement":
+			return menuCatFS
+		case "disclosure":
+			return menuCatNFS
+		}
+	}
+	return menuCatUnknown
+}
+
+// role gets the name of the role of the report in lower case letters only.
+// Ex: http://www.apple.com/role/CONDENSEDCONSOLIDATEDBALANCESHEETS
+func (r summaryReport) role() string {
+	name := r.Role[strings.LastIndex(r.Role, "/")+1:]
+	return strings.Map(func(c rune) rune {
+		if !unicode.IsLetter(c) {
+			return -1
+		}
+		return unicode.ToLower(c)
+	}, name)
+}
+
+// roleDocType identifies the report by its role. The role is not always
+// descriptive, in which case the report is not identified.
+func (r summaryReport) roleDocType(menu menuCat) (filingDocType, bool) {
+	role := r.role()
+	switch menu {
+	case menuCatCover:
+		if role == "cover" || strings.Contains(role, "coverpage") ||
+			strings.Contains(role, "documentandentityinformation") {
+			return filingDocEN, true
+		}
+	case menuCatFS:
+		for _, s := range roleStatements {
+			if strings.Contains(role, s.key) {
+				return s.doc, true
+			}
+		}
+	case menuCatNFS:
+		for _, n := range roleNotes {
+			if strings.Contains(role, n.key) {
+				return n.doc, true
+			}
+		}
+	}
+	return filingDocIg, false
+}
+
+// docType identifies the report by its role falling back to the names of
+// the report
+func (r summaryReport) docType() filingDocType {
+	menu := r.menuCategory()
+	if doc, ok := r.roleDocType(menu); ok {
+		return doc
+	}
+	if doc := lookupDocType(r.ShortName, menu); doc != filingDocIg {
+		return doc
+	}
+	parts := strings.SplitN(r.LongName, " - ", 3)
+	return lookupDocType(parts[len(parts)-1], menu)
+}
+
+/*
+  The filing summary parser
+  - The FilingSummary.xml of a filing lists the R pages of the filing in
+    the order they are presented
+  - Each report is identified by its role, menu category and names
+  - The first report identified as a type of document is the document
+  Returns the links to the reports relative to the given base
+*/
+func filingSummaryParser(page io.Reader, base string) (map[filingDocType]string, error) {
+	var summary struct {
+		Reports []summaryReport `xml:"MyReports>Report"`
+	}
+	d := xml.NewDecoder(page)
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := d.Decode(&summary); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(summary.Reports, func(i, j int) bool {
+		return summary.Reports[i].Position < summary.Reports[j].Position
+	})
+
+	docs := make(map[filingDocType]string)
+	for _, r := range summary.Reports {
+		if r.fileName() == ".htm" {
+			continue
+		}
+		doc := r.docType()
+		if _, ok := docs[doc]; !ok && doc != filingDocIg {
+			docs[doc] = base + r.fileName()
+		}
+	}
+	_, bs := docs[filingDocBS]
+	_, ops := docs[filingDocOps]
+	_, inc := docs[filingDocInc]
+	_, cf := docs[filingDocCF]
+	if !bs && !ops && !inc && !cf {
+		return nil, errors.New("No financial statements found in the filing summary")
+	}
+	if ret := getMissingDocs(docs); ret != "" {
+		log.Println("Did not find the following filing documents: " + ret)
+	}
+	return docs, nil
+}
@@ -0,0 +1,195 @@
+package edgar
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// yearToDateValue finds the value of the data for the longest period to
+// date of a quarterly report, ending with the report. Ex: the nine months
+// to date of the third quarter
+func (fr *financialReport) yearToDateValue(finType finDataType, end Timestamp) (periodValue, bool) {
+	var ret periodValue
+	found := false
+	for _, p := range fr.Periods {
+		if p.Data != finType || p.Months <= 0 || p.Months >= 12 || !time.Time(p.End).Equal(time.Time(end)) {
+			continue
+		}
+		if !found || p.Months > ret.Months {
+			ret = p
+			found = true
+		}
+	}
+	return ret, found
+}
+
+// priorYearValue finds the value of the data for a period as long as the
+// given period and ending about a year before it. Statements report the
+// same period of the prior year for comparison.
+func (fr *financialReport) priorYearValue(cur periodValue) (periodValue, bool) {
+	from, to := time.Time(cur.End).AddDate(0, -13, 0), time.Time(cur.End).AddDate(0, -11, 0)
+	for _, p := range fr.Periods {
+		if p.Data == cur.Data && p.Months == cur.Months &&
+			time.Time(p.End).After(from) && time.Time(p.End).Before(to) {
+			return p, true
+		}
+	}
+	return periodValue{}, false
+}
+
+// trailingYear makes the report of the twelve months ending with a
+// quarterly report out of the annual report of the previous fiscal year.
+// The flow data is the data of the year plus the year to date less the
+// year to date of the prior year, which is taken from the quarterly report
+// or else from the quarterly report of the prior year. The balance sheet
+// and entity information are the ones of the quarterly report, as are the
+// custom metrics reported as of the end of the quarter. It tells if
+// the quarterly report of the prior year is needed for data that is not
+// compared with the prior year in the quarterly report.
+func trailingYear(annual *financialReport, quarter *financialReport, prior *financialReport) (*financialReport, bool) {
+	fr := newFinancialReport(quarter.DocType)
+	*fr.Entity = *quarter.Entity
+	*fr.Bs = *quarter.Bs
+	fr.Entity.FiscalPeriod = "TTM"
+	end := quarter.periodEnd()
+	needPrior := false
+
+	for _, p := range quarter.Periods {
+		if p.Months == 0 && time.Time(p.End).Equal(time.Time(end)) {
+			fr.Periods = append(fr.Periods, p)
+		}
+	}
+
+	trail := func(data interface{}, year interface{}) {
+		t := reflect.TypeOf(data).Elem()
+		v := reflect.ValueOf(data).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			entity, ok := t.Field(i).Tag.Lookup("entity")
+			if !ok || scaleEntity(entity) == scaleEntityShares {
+				continue
+			}
+			if !isCollectedDataSet(year, t.Field(i).Name) {
+				continue
+			}
+			finType := finDataType(t.Field(i).Tag.Get("json"))
+			cur, ok := quarter.yearToDateValue(finType, end)
+			if !ok {
+				continue
+			}
+			last, ok := quarter.priorYearValue(cur)
+			if !ok && prior != nil {
+				last, ok = prior.priorYearValue(cur)
+			}
+			if !ok {
+				needPrior = true
+				continue
+			}
+			val := reflect.ValueOf(year).Elem().Field(i).Float() + cur.Value - last.Value
+			v.Field(i).SetFloat(val)
+			setCollectedData(data, i)
+			fr.setDerived(finType, true)
+			fr.Periods = append(fr.Periods, periodValue{
+				Data:   finType,
+				End:    end,
+				Months: 12,
+				Value:  val,
+			})
+		}
+	}
+	trail(fr.Ops, annual.Ops)
+	trail(fr.Cf, annual.Cf)
+
+	// Custom metrics reported as of the end of the quarter are kept as is
+	for fin, entity := range quarter.CustomUnits {
+		fr.setCustomUnit(fin, entity)
+		if val, ok := quarter.Custom[fin]; ok && quarter.period(fin, end, 0) >= 0 {
+			fr.setCustomValue(fin, val)
+			continue
+		}
+		year, ok := annual.Custom[fin]
+		if !ok || entity == scaleEntityShares {
+			continue
+		}
+		cur, ok := quarter.yearToDateValue(fin, end)
+		if !ok {
+			continue
+		}
+		last, ok := quarter.priorYearValue(cur)
+		if !ok && prior != nil {
+			last, ok = prior.priorYearValue(cur)
+		}
+		if !ok {
+			needPrior = true
+			continue
+		}
+		val := year + cur.Value - last.Value
+		fr.setCustomValue(fin, val)
+		fr.setDerived(fin, true)
+		fr.Periods = append(fr.Periods, periodValue{
+			Data:   fin,
+			End:    end,
+			Months: 12,
+			Value:  val,
+		})
+	}
+	return fr, needPrior
+}
+
+// filedBy gets the date of the latest filing of a type filed on or before
+// the given date
+func (c *company) filedBy(fileType FilingType, ts time.Time) (time.Time, bool) {
+	return c.filedBefore(fileType, ts.AddDate(0, 0, 1))
+}
+
+func (c *company) TTM(ts time.Time) (Filing, error) {
+	return c.TTMContext(context.Background(), ts)
+}
+
+func (c *company) TTMContext(ctx context.Context, ts time.Time) (Filing, error) {
+	annualFiled, ok := c.filedBy(FilingType10K, ts)
+	if !ok {
+		return nil, errors.New("No 10-K filed by " + getDateString(ts))
+	}
+	quarterFiled, ok := c.filedBy(FilingType10Q, ts)
+	if !ok || quarterFiled.Before(annualFiled) {
+		// The latest filing is the 10-K which reports the twelve months
+		return c.FilingContext(ctx, FilingType10K, annualFiled)
+	}
+
+	f, err := c.FilingContext(ctx, FilingType10K, annualFiled)
+	if err != nil {
+		return nil, err
+	}
+	annual := f.(*filing)
+	f, err = c.FilingContext(ctx, FilingType10Q, quarterFiled)
+	if err != nil {
+		return nil, err
+	}
+	quarter := f.(*filing)
+
+	// The 10-K is for the fiscal year before the year of the 10-Q
+	end := time.Time(quarter.FinData.periodEnd())
+	if yearEnd := time.Time(annual.FinData.periodEnd()); !yearEnd.Before(end) || !yearEnd.After(end.AddDate(-1, 0, 0)) {
+		return nil, errors.New("The 10-K filed on " + getDateString(annualFiled) +
+			" is not for the fiscal year before the 10-Q filed on " + getDateString(quarterFiled))
+	}
+
+	fr, needPrior := trailingYear(annual.FinData, quarter.FinData, nil)
+	if needPrior {
+		// The quarterly filing of the prior year is filed about a year before
+		if priorFiled, ok := c.filedBefore(FilingType10Q, quarterFiled.AddDate(0, -10, 0)); ok {
+			f, err := c.FilingContext(ctx, FilingType10Q, priorFiled)
+			if err != nil {
+				return nil, err
+			}
+			fr, _ = trailingYear(annual.FinData, quarter.FinData, f.(*filing).FinData)
+		}
+	}
+	return &filing{
+		Company: quarter.Company,
+		Date:    quarter.Date,
+		FinData: fr,
+	}, nil
+}
@@ -6,17 +6,16 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 func normalizeNumber(str string) (float64, error) {
 	negative := float64(1)
-	//Remove any leading spaces or $ signs
+	//Remove any leading spaces or currency signs like $, € or NT$
 	if strings.Contains(str, "(") && strings.Contains(str, ")") {
 		negative *= -1
 	}
-	str = strings.TrimLeft(str, " ")
-	str = strings.TrimLeft(str, "$")
-	str = strings.TrimLeft(str, " ")
+	str = trimCurrency(str)
 	str = strings.TrimRight(str, " ")
 	str = strings.TrimLeft(str, "(")
 	str = strings.TrimRight(str, ")")
@@ -65,7 +64,7 @@ func filingScale(strs []string, t filingDocType) map[scaleEntity]scaleFactor {
 				} else if strings.Contains(part, "million") {
 					ret[scaleEntityShares] = scaleMillion
 				}
-			} else if strings.Contains(part, "$") || strings.Contains(part, "usd") {
+			} else if isCurrency(part) {
 				//Money scale
 				if strings.Contains(part, "thousand") {
 					ret[scaleEntityMoney] = scaleThousand
@@ -78,6 +77,141 @@ func filingScale(strs []string, t filingDocType) map[scaleEntity]scaleFactor {
 	return ret
 }
 
+// currencyCodes are the codes of the currencies that filings are reported
+// in. Foreign private issuers report in currencies other than USD.
+var currencyCodes = []string{"USD", "EUR", "GBP", "JPY", "CAD", "TWD", "CHF", "KRW", "INR", "CNY", "RMB"}
+
+// currencySymbols are the symbols that amounts are written with, the
+// longest first. Ex: NT$ 2.36
+var currencySymbols = []string{"NT$", "US$", "HK$", "$", "€", "£", "¥", "₩", "₹"}
+
+// isCurrencyCode tells if a word is the code of a currency
+func isCurrencyCode(word string) bool {
+	for _, c := range currencyCodes {
+		if strings.EqualFold(word, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimCurrency removes the leading spaces and the currency code or symbol
+// that an amount is written with. Ex: $ 2.36, NT$ 2.36 or EUR 2.36
+func trimCurrency(str string) string {
+	str = strings.TrimLeft(str, " ")
+	for _, c := range currencySymbols {
+		if strings.HasPrefix(str, c) {
+			return strings.TrimLeft(strings.TrimPrefix(str, c), " ")
+		}
+	}
+	i := strings.IndexFunc(str, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i < 0 {
+		i = len(str)
+	}
+	if isCurrencyCode(str[:i]) {
+		return strings.TrimLeft(str[i:], " ")
+	}
+	return str
+}
+
+// isCurrency tells if the scale in the heading of a report is for money.
+// The heading names the currency by its code or symbol. Ex: EUR (€)
+func isCurrency(str string) bool {
+	for _, c := range currencySymbols {
+		if strings.Contains(str, c) {
+			return true
+		}
+	}
+	for _, word := range strings.FieldsFunc(str, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if isCurrencyCode(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportCurrency gets the currency that the money of a report is reported
+// in out of the heading of the report. Ex: USD ($) in the title
+// CONSOLIDATED BALANCE SHEETS - USD ($) $ in Millions. It is empty when
+// the heading has no currency code.
+func reportCurrency(strs []string) string {
+	for _, str := range strs {
+		for _, word := range strings.FieldsFunc(str, func(r rune) bool { return !unicode.IsLetter(r) }) {
+			if isCurrencyCode(word) {
+				return strings.ToUpper(word)
+			}
+		}
+	}
+	return ""
+}
+
+// tagConcept gets the XBRL concept of the tag of a row of a report.
+// Ex: us-gaap:Revenues for defref_us-gaap_Revenues
+func tagConcept(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, "defref_") {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(tag, "defref_"), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0] + ":" + parts[1], true
+}
+
+// conceptTag gets the concept in the form of tagConcept out of the forms
+// that a concept is written in. Ex: us-gaap:Revenues, us-gaap_Revenues or
+// defref_us-gaap_Revenues
+func conceptTag(concept string) string {
+	concept = strings.TrimSpace(concept)
+	if c, ok := tagConcept(concept); ok {
+		return c
+	}
+	if c, ok := tagConcept("defref_" + concept); ok && !strings.Contains(concept, ":") {
+		return c
+	}
+	return concept
+}
+
+// conceptUnit gets the unit and the scale entity of the values of a concept
+// reported in a report. The concepts that are not collected are guessed
+// from their names. Ex: EarningsPerShareBasic is in USD/shares
+func conceptUnit(concept string, finType finDataType, currency string) (string, scaleEntity) {
+	entity := scaleEntityMoney
+	if _, e, ok := metricField(finType); ok {
+		entity = e
+	} else if name := concept[strings.Index(concept, ":")+1:]; strings.Contains(name, "PerShare") {
+		entity = scaleEntityPerShare
+	} else if strings.Contains(name, "Shares") || strings.Contains(name, "NumberOf") {
+		entity = scaleEntityShares
+	}
+	switch entity {
+	case scaleEntityShares:
+		return "shares", entity
+	case scaleEntityPerShare:
+		return currency + "/shares", entity
+	}
+	return currency, entity
+}
+
+// unitName gets the name of the unit of an XBRL fact without the prefixes
+// of the measures. Ex: USD/shares for iso4217:USD/xbrli:shares
+func unitName(unit string) string {
+	var b strings.Builder
+	start := 0
+	for i, r := range unit + "/" {
+		if r != '/' && r != '*' {
+			continue
+		}
+		m := unit[start:i]
+		b.WriteString(m[strings.Index(m, ":")+1:])
+		if i < len(unit) {
+			b.WriteRune(r)
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
 func getFinDataXBRLTag(onclick string) (string, error) {
 	if strings.Contains(onclick, "showAR") {
 		d := strings.Split(onclick, `'`)
@@ -0,0 +1,479 @@
+package edgar
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parser is the backend used to parse the financial data of a filing
+type Parser int
+
+const (
+	// ParseRendered parses the R pages of a filing rendered by the
+	// interactive viewer of EDGAR
+	ParseRendered Parser = iota
+
+	// ParseXBRL parses the XBRL instance document of a filing. The facts
+	// are read with their contexts, units and decimals instead of being
+	// scraped from the presentation of the filing.
+	ParseXBRL
+
+	// ParseInlineXBRL parses the facts embedded in the primary document of
+	// a filing made with inline XBRL
+	ParseInlineXBRL
+)
+
+var (
+	indexURL = "Archives/edgar/data/%s/%s/index.json"
+	docURL   = "Archives/edgar/data/%s/%s/%s"
+
+	// Facts are reported as is in an instance document
+	xbrlScale = map[scaleEntity]scaleFactor{
+		scaleEntityShares:   scaleNone,
+		scaleEntityMoney:    scaleNone,
+		scaleEntityPerShare: scaleNone,
+	}
+
+	// Cash outflows are presented as negative numbers in the statements
+	// but are reported as positive facts
+	xbrlOutflows = map[finDataType]bool{
+		finDataCapEx:    true,
+		finDataDividend: true,
+	}
+)
+
+type xbrlContext struct {
+	ID     string `xml:"id,attr"`
+	Entity struct {
+		Segment *struct{} `xml:"segment"`
+	} `xml:"entity"`
+	Scenario *struct{} `xml:"scenario"`
+	Period   struct {
+		Instant   string `xml:"instant"`
+		StartDate string `xml:"startDate"`
+		EndDate   string `xml:"endDate"`
+	} `xml:"period"`
+}
+
+// dimensional tells if the context is for a member of a dimension, like a
+// segment of the business, instead of the whole company
+func (ctx xbrlContext) dimensional() bool {
+	return ctx.Entity.Segment != nil || ctx.Scenario != nil
+}
+
+func (ctx xbrlContext) end() string {
+	if ctx.Period.Instant != "" {
+		return strings.TrimSpace(ctx.Period.Instant)
+	}
+	return strings.TrimSpace(ctx.Period.EndDate)
+}
+
+// days is the length of the period of the context. Instants are 0 days long.
+func (ctx xbrlContext) days() int {
+	if ctx.Period.Instant != "" {
+		return 0
+	}
+	start := time.Time(getDate(strings.TrimSpace(ctx.Period.StartDate)))
+	end := time.Time(getDate(ctx.end()))
+	return int(end.Sub(start).Hours() / 24)
+}
+
+type xbrlUnit struct {
+	ID      string   `xml:"id,attr"`
+	Measure []string `xml:"measure"`
+	Divide  struct {
+		Numerator   []string `xml:"unitNumerator>measure"`
+		Denominator []string `xml:"unitDenominator>measure"`
+	} `xml:"divide"`
+}
+
+func (u xbrlUnit) String() string {
+	if len(u.Measure) > 0 {
+		return strings.Join(u.Measure, "*")
+	}
+	return strings.Join(u.Divide.Numerator, "*") + "/" + strings.Join(u.Divide.Denominator, "*")
+}
+
+// xbrlFact is a fact reported in a filing. The key of the fact is the tag
+// of the concept of the fact in the same form as the rendered pages.
+// Ex: defref_us-gaap_Assets
+type xbrlFact struct {
+	Key      string
+	Prefix   string
+	Context  string
+	Unit     string
+	Decimals string
+	Value    string
+}
+
+// precision gets the number of decimals that the fact is accurate to
+func (f xbrlFact) precision() int {
+	if f.Decimals == "INF" || f.Decimals == "" {
+		return math.MaxInt32
+	}
+	d, err := strconv.Atoi(f.Decimals)
+	if err != nil {
+		return math.MinInt32
+	}
+	return d
+}
+
+// xbrlInstance is what is read out of an XBRL instance document
+type xbrlInstance struct {
+	Contexts map[string]xbrlContext
+	Units    map[string]string
+	Facts    []xbrlFact
+}
+
+func newXBRLInstance() *xbrlInstance {
+	return &xbrlInstance{
+		Contexts: make(map[string]xbrlContext),
+		Units:    make(map[string]string),
+	}
+}
+
+// xbrlInstanceParser reads the contexts, units and facts of an XBRL
+// instance document. The concepts are identified by the namespace prefixes
+// declared on the root of the document.
+func xbrlInstanceParser(page io.Reader) (*xbrlInstance, error) {
+	inst := newXBRLInstance()
+	prefixes := make(map[string]string)
+	d := xml.NewDecoder(page)
+	d.Strict = false
+	// Instances are mostly declared as US-ASCII which is a subset of UTF-8
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Space == "xmlns" {
+				prefixes[a.Value] = a.Name.Local
+			}
+		}
+		switch start.Name.Local {
+		case "context":
+			var ctx xbrlContext
+			if err := d.DecodeElement(&ctx, &start); err != nil {
+				return nil, err
+			}
+			inst.Contexts[ctx.ID] = ctx
+			continue
+		case "unit":
+			var u xbrlUnit
+			if err := d.DecodeElement(&u, &start); err != nil {
+				return nil, err
+			}
+			inst.Units[u.ID] = u.String()
+			continue
+		}
+
+		fact := xbrlFact{Prefix: prefixes[start.Name.Space]}
+		isNil := false
+		for _, a := range start.Attr {
+			switch a.Name.Local {
+			case "contextRef":
+				fact.Context = a.Value
+			case "unitRef":
+				fact.Unit = a.Value
+			case "decimals":
+				fact.Decimals = a.Value
+			case "nil":
+				isNil = a.Value == "true"
+			}
+		}
+		if fact.Context == "" {
+			continue
+		}
+		var val struct {
+			Value string `xml:",chardata"`
+		}
+		if err := d.DecodeElement(&val, &start); err != nil {
+			return nil, err
+		}
+		if isNil || fact.Prefix == "" {
+			continue
+		}
+		fact.Key = "defref_" + fact.Prefix + "_" + start.Name.Local
+		fact.Value = strings.TrimSpace(val.Value)
+		inst.Facts = append(inst.Facts, fact)
+	}
+	if len(inst.Facts) == 0 {
+		return nil, errors.New("No facts found in the XBRL instance")
+	}
+	return inst, nil
+}
+
+// periodEnd gets the end of the period of the report of the filing
+func (inst *xbrlInstance) periodEnd() string {
+	for _, f := range inst.Facts {
+		if f.Key == "defref_dei_DocumentPeriodEndDate" && !inst.Contexts[f.Context].dimensional() {
+			return f.Value
+		}
+	}
+	// Otherwise the report is for the latest period reported
+	var end string
+	for _, ctx := range inst.Contexts {
+		if !ctx.dimensional() && ctx.Period.Instant == "" && ctx.end() > end {
+			end = ctx.end()
+		}
+	}
+	return end
+}
+
+// better tells if fact a is a better choice than fact b for the data of
+// the report. Facts for the period of the report are preferred. Annual
+// reports prefer the longest period ending with the report and quarterly
+// reports the shortest. Between the facts for the same period the most
+// precise one is preferred.
+func (inst *xbrlInstance) better(a xbrlFact, b xbrlFact, end string, annual bool) bool {
+	ca, cb := inst.Contexts[a.Context], inst.Contexts[b.Context]
+	if (ca.end() == end) != (cb.end() == end) {
+		return ca.end() == end
+	}
+	if ca.end() != cb.end() {
+		return ca.end() > cb.end()
+	}
+	if ca.days() != cb.days() {
+		return (ca.days() > cb.days()) == annual
+	}
+	return a.precision() > b.precision()
+}
+
+// financialReport fills a financial report with the facts of the instance.
+// The tags are mapped to metrics with the mappings of the company.
+func (inst *xbrlInstance) financialReport(fileType FilingType, tags *metricTags) *financialReport {
+	fr := newFinancialReport(fileType)
+	fr.tags = tags
+	end := inst.periodEnd()
+	annual := originalType(fileType) != FilingType10Q
+
+	var keys []string
+	for _, f := range inst.Facts {
+		keys = append(keys, f.Key)
+	}
+	fr.taxonomy = detectTaxonomy(keys)
+
+	// The best fact for every concept, in the order the concepts are reported
+	best := make(map[string]xbrlFact)
+	var order []string
+	for _, f := range inst.Facts {
+		ctx, ok := inst.Contexts[f.Context]
+		if !ok || ctx.dimensional() {
+			continue
+		}
+		if f.Prefix == "dei" && setDocumentInfo(fr.Entity, f.Key, f.Value) {
+			continue
+		}
+		if fr.dataType(f.Key) == finDataUnknown {
+			continue
+		}
+		// The entity information is reported as of the date of filing
+		if ctx.end() != end && f.Prefix != "dei" {
+			continue
+		}
+		cur, ok := best[f.Key]
+		if !ok {
+			order = append(order, f.Key)
+			best[f.Key] = f
+		} else if inst.better(f, cur, end, annual) {
+			best[f.Key] = f
+		}
+	}
+
+	for _, key := range order {
+		f := best[key]
+		finType := fr.dataType(key)
+		num, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			continue
+		}
+		if xbrlOutflows[finType] {
+			num = -num
+		}
+		t := filingDocIg
+		if doc, ok := strictDataToDocMap[finType]; ok {
+			t = doc
+		}
+		setData(fr, finType, strconv.FormatFloat(num, 'f', -1, 64), xbrlScale, t)
+	}
+	inst.periods(fr)
+	if !annual {
+		fr.quarterly()
+	}
+	return fr
+}
+
+// months is the length of the period of the context in months. Instants
+// are 0 months long.
+func (ctx xbrlContext) months() int {
+	return int(math.Round(float64(ctx.days()) / 30.44))
+}
+
+// periods adds the values of the facts for every period reported in the
+// instance to the report, along with the facts of the concepts that are not
+// collected. The most precise fact for a period is kept.
+func (inst *xbrlInstance) periods(fr *financialReport) {
+	precision := make(map[int]int)
+	factPrecision := make(map[int]int)
+	for _, f := range inst.Facts {
+		ctx, ok := inst.Contexts[f.Context]
+		if !ok || ctx.dimensional() {
+			continue
+		}
+		num, err := strconv.ParseFloat(f.Value, 64)
+		if err != nil {
+			continue
+		}
+		if concept, ok := tagConcept(f.Key); ok {
+			fact := factValue{
+				Concept: concept,
+				Value:   num,
+				Unit:    unitName(inst.Units[f.Unit]),
+				Scale:   float64(scaleNone),
+				End:     getDate(ctx.end()),
+				Months:  ctx.months(),
+			}
+			if i := fr.fact(fact); i < 0 {
+				fr.Facts = append(fr.Facts, fact)
+				factPrecision[len(fr.Facts)-1] = f.precision()
+			} else if factPrecision[i] < f.precision() {
+				fr.Facts[i] = fact
+				factPrecision[i] = f.precision()
+			}
+		}
+		finType := fr.dataType(f.Key)
+		if finType == finDataUnknown {
+			continue
+		}
+		if xbrlOutflows[finType] {
+			num = -num
+		}
+		t := filingDocIg
+		if doc, ok := strictDataToDocMap[finType]; ok {
+			t = doc
+		}
+		end := getDate(ctx.end())
+		i := fr.period(finType, end, ctx.months())
+		if i >= 0 && precision[i] >= f.precision() {
+			continue
+		}
+		if i >= 0 {
+			fr.Periods[i].Value = num
+		} else {
+			fr.addPeriod(finType, end, ctx.months(), strconv.FormatFloat(num, 'f', -1, 64), xbrlScale, t)
+			if i = fr.period(finType, end, ctx.months()); i < 0 {
+				continue
+			}
+		}
+		precision[i] = f.precision()
+	}
+}
+
+// filingDocument is a document listed in the index of a filing
+type filingDocument struct {
+	Name string          `json:"name"`
+	Size json.RawMessage `json:"size"`
+}
+
+// size gets the size of the document in bytes. EDGAR lists the size as a
+// string which is empty for directories.
+func (d filingDocument) size() int {
+	size, _ := strconv.Atoi(strings.Trim(string(d.Size), `"`))
+	return size
+}
+
+func filingIndexParser(page io.Reader) ([]filingDocument, error) {
+	var index struct {
+		Directory struct {
+			Item []filingDocument `json:"item"`
+		} `json:"directory"`
+	}
+	if err := json.NewDecoder(page).Decode(&index); err != nil {
+		return nil, err
+	}
+	return index.Directory.Item, nil
+}
+
+// instanceDocument finds the XBRL instance among the documents of a filing.
+// The instance extracted from an inline XBRL filing is preferred.
+func instanceDocument(docs []filingDocument) (string, error) {
+	var instance string
+	for _, doc := range docs {
+		name := strings.ToLower(doc.Name)
+		if !strings.HasSuffix(name, ".xml") || name == "filingsummary.xml" {
+			continue
+		}
+		if strings.HasSuffix(name, "_cal.xml") || strings.HasSuffix(name, "_def.xml") ||
+			strings.HasSuffix(name, "_lab.xml") || strings.HasSuffix(name, "_pre.xml") {
+			continue
+		}
+		if strings.HasSuffix(name, "_htm.xml") {
+			return doc.Name, nil
+		}
+		if instance == "" {
+			instance = doc.Name
+		}
+	}
+	if instance == "" {
+		return "", errors.New("No XBRL instance found in the filing")
+	}
+	return instance, nil
+}
+
+// getFilingDocument gets a document of a filing. The document is chosen
+// out of the documents listed in the index of the filing.
+func (c *client) getFilingDocument(
+	ctx context.Context,
+	url string,
+	choose func([]filingDocument) (string, error)) (io.ReadCloser, error) {
+
+	cik, an := parseCikAndDocID(url)
+	page, err := c.getPage(ctx, c.url(fmt.Sprintf(indexURL, cik, an)))
+	if err != nil {
+		return nil, err
+	}
+	docs, err := filingIndexParser(page)
+	page.Close()
+	if err != nil {
+		return nil, err
+	}
+	name, err := choose(docs)
+	if err != nil {
+		return nil, err
+	}
+	return c.getPage(ctx, c.url(fmt.Sprintf(docURL, cik, an, name)))
+}
+
+// getXBRLData gets the data of a filing from its XBRL instance document
+func (c *client) getXBRLData(ctx context.Context, url string, fileType FilingType, tags *metricTags) (*financialReport, error) {
+	page, err := c.getFilingDocument(ctx, url, instanceDocument)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := xbrlInstanceParser(page)
+	page.Close()
+	if err != nil {
+		return nil, err
+	}
+	fr := inst.financialReport(fileType, tags)
+	return fr, validateFinancialReport(fr)
+}
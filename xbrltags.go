@@ -4,7 +4,13 @@ import (
 	"strings"
 )
 
+// taxonomy is the XBRL taxonomy used by a filing
+type taxonomy string
+
 var (
+	taxonomyGAAP taxonomy = "us-gaap"
+	taxonomyIFRS taxonomy = "ifrs-full"
+
 	// A Map of XBRL tags to financial data type
 	// This map contains the corresponding GAAP tag and a version of the tag
 	// without the GAAP keyword in case the company has only file non-gaap
@@ -114,11 +120,209 @@ var (
 		"defref_dei_EntityCommonStockSharesOutstanding": finDataSharesOutstanding,
 		"EntityCommonStockSharesOutstanding":            finDataSharesOutstanding,
 	}
+
+	// The concepts of the tags of xbrlTags by metric in the order they are
+	// preferred in when a company reports more than one of them. The totals
+	// come before the narrower concepts.
+	xbrlConcepts = map[finDataType][]string{
+		//Balance Sheet info
+		finDataTotalEquity: {"us-gaap:StockholdersEquity"},
+		finDataRetained: {
+			"us-gaap:RetainedEarningsAccumulatedDeficit",
+			"us-gaap:RetainedEarningsAccumulatedDeficitAndAccumulatedOtherComprehensiveIncomeLossNetOfTax",
+		},
+		finDataCLiab:      {"us-gaap:LiabilitiesCurrent"},
+		finDataCAssets:    {"us-gaap:AssetsCurrent"},
+		finDataAssets:     {"us-gaap:Assets"},
+		finDataLiab:       {"us-gaap:Liabilities"},
+		finDataCash:       {"us-gaap:CashAndCashEquivalentsAtCarryingValue"},
+		finDataGoodwill:   {"us-gaap:Goodwill"},
+		finDataIntangible: {"us-gaap:IntangibleAssetsNetExcludingGoodwill"},
+		finDataLDebt: {
+			"us-gaap:LongTermDebtNoncurrent",
+			"us-gaap:LongTermDebtAndCapitalLeaseObligations",
+		},
+		finDataSDebt: {
+			"us-gaap:DebtCurrent",
+			"us-gaap:ShortTermBorrowings",
+			"us-gaap:LongTermDebtAndCapitalLeaseObligationsCurrent",
+		},
+		finDataDeferred: {"us-gaap:DeferredRevenueCurrent"},
+
+		//Operations Sheet info
+		finDataRevenue: {
+			"us-gaap:Revenues",
+			"us-gaap:RevenueFromContractWithCustomerExcludingAssessedTax",
+			"us-gaap:SalesRevenueNet",
+		},
+		finDataCostOfRevenue: {
+			"us-gaap:CostOfRevenue",
+			"us-gaap:CostOfGoodsAndServicesSold",
+			"us-gaap:CostOfGoodsSold",
+			"us-gaap:CostOfGoodsSoldExcludingAmortizationOfAcquiredIntangibleAssets",
+			"us-gaap:CostOfPurchasedOilAndGas",
+		},
+		finDataGrossMargin: {"us-gaap:GrossProfit"},
+		finDataOpsExpense: {
+			"us-gaap:OperatingExpenses",
+			"us-gaap:CostsAndExpenses",
+			"us-gaap:OtherCostAndExpenseOperating",
+		},
+		finDataOpsIncome: {
+			"us-gaap:OperatingIncomeLoss",
+			"us-gaap:IncomeLossFromContinuingOperationsBeforeIncomeTaxesExtraordinaryItemsNoncontrollingInterest",
+			"us-gaap:IncomeLossFromContinuingOperationsBeforeIncomeTaxesMinorityInterestAndIncomeLossFromEquityMethodInvestments",
+			"us-gaap:IncomeLossFromContinuingOperationsIncludingPortionAttributableToNoncontrollingInterest",
+			"us-gaap:IncomeLossIncludingPortionAttributableToNoncontrollingInterest",
+		},
+		finDataNetIncome: {
+			"us-gaap:NetIncomeLoss",
+			"us-gaap:ProfitLoss",
+			"us-gaap:NetIncomeLossAvailableToCommonStockholdersBasic",
+		},
+		finDataWAShares: {"us-gaap:WeightedAverageNumberOfDilutedSharesOutstanding"},
+		finDataDps:      {"us-gaap:CommonStockDividendsPerShareDeclared"},
+
+		//Cash Flow Sheet info
+		finDataOpCashFlow: {
+			"us-gaap:NetCashProvidedByUsedInOperatingActivities",
+			"us-gaap:NetCashProvidedByUsedInOperatingActivitiesContinuingOperations",
+		},
+		finDataCapEx: {
+			"us-gaap:PaymentsToAcquirePropertyPlantAndEquipment",
+			"us-gaap:PaymentsToAcquireProductiveAssets",
+			"us-gaap:CapitalExpendituresAndInvestments",
+		},
+		finDataDividend: {
+			"us-gaap:PaymentsOfDividends",
+			"us-gaap:PaymentsOfDividendsCommonStock",
+		},
+		finDataInterest: {
+			"us-gaap:InterestPaidNet",
+			"us-gaap:InterestAndDebtExpense",
+			"us-gaap:InterestIncomeExpenseNet",
+		},
+		//Entity sheet information
+		finDataSharesOutstanding: {"dei:EntityCommonStockSharesOutstanding"},
+	}
+
+	// A Map of IFRS XBRL tags to financial data type used for filings made
+	// with the IFRS taxonomy, mostly the 20-F and 40-F filings of foreign
+	// private issuers. Like the GAAP map it contains a version of the tag
+	// without the taxonomy for the company specific tags.
+	ifrsTags = map[string]finDataType{
+		//Balance Sheet info
+		"defref_ifrs-full_Equity": finDataTotalEquity,
+		"Equity":                  finDataTotalEquity,
+		"defref_ifrs-full_EquityAttributableToOwnersOfParent":      finDataTotalEquity,
+		"EquityAttributableToOwnersOfParent":                       finDataTotalEquity,
+		"defref_ifrs-full_RetainedEarnings":                        finDataRetained,
+		"RetainedEarnings":                                         finDataRetained,
+		"defref_ifrs-full_CurrentLiabilities":                      finDataCLiab,
+		"CurrentLiabilities":                                       finDataCLiab,
+		"defref_ifrs-full_CurrentAssets":                           finDataCAssets,
+		"CurrentAssets":                                            finDataCAssets,
+		"defref_ifrs-full_Assets":                                  finDataAssets,
+		"Assets":                                                   finDataAssets,
+		"defref_ifrs-full_Liabilities":                             finDataLiab,
+		"Liabilities":                                              finDataLiab,
+		"defref_ifrs-full_CashAndCashEquivalents":                  finDataCash,
+		"CashAndCashEquivalents":                                   finDataCash,
+		"defref_ifrs-full_Goodwill":                                finDataGoodwill,
+		"Goodwill":                                                 finDataGoodwill,
+		"defref_ifrs-full_IntangibleAssetsOtherThanGoodwill":       finDataIntangible,
+		"IntangibleAssetsOtherThanGoodwill":                        finDataIntangible,
+		"defref_ifrs-full_NoncurrentPortionOfNoncurrentBorrowings": finDataLDebt,
+		"NoncurrentPortionOfNoncurrentBorrowings":                  finDataLDebt,
+		"defref_ifrs-full_LongtermBorrowings":                      finDataLDebt,
+		"LongtermBorrowings":                                       finDataLDebt,
+		"defref_ifrs-full_NoncurrentBorrowings":                    finDataLDebt,
+		"NoncurrentBorrowings":                                     finDataLDebt,
+		"defref_ifrs-full_CurrentBorrowingsAndCurrentPortionOfNoncurrentBorrowings": finDataSDebt,
+		"CurrentBorrowingsAndCurrentPortionOfNoncurrentBorrowings":                  finDataSDebt,
+		"defref_ifrs-full_ShorttermBorrowings":                                      finDataSDebt,
+		"ShorttermBorrowings":                                                       finDataSDebt,
+		"defref_ifrs-full_CurrentBorrowings":                                        finDataSDebt,
+		"CurrentBorrowings":                                                         finDataSDebt,
+		"defref_ifrs-full_CurrentContractLiabilities":                               finDataDeferred,
+		"CurrentContractLiabilities":                                                finDataDeferred,
+		"defref_ifrs-full_CurrentDeferredIncome":                                    finDataDeferred,
+		"CurrentDeferredIncome":                                                     finDataDeferred,
+		"defref_ifrs-full_CurrentInvestments":                                       finDataSecurities,
+		"CurrentInvestments":                                                        finDataSecurities,
+		"defref_ifrs-full_OtherCurrentFinancialAssets":                              finDataSecurities,
+		"OtherCurrentFinancialAssets":                                               finDataSecurities,
+
+		//Operations Sheet info
+		"defref_ifrs-full_Revenue": finDataRevenue,
+		"Revenue":                  finDataRevenue,
+		"defref_ifrs-full_RevenueFromContractsWithCustomers":                  finDataRevenue,
+		"RevenueFromContractsWithCustomers":                                   finDataRevenue,
+		"defref_ifrs-full_CostOfSales":                                        finDataCostOfRevenue,
+		"CostOfSales":                                                         finDataCostOfRevenue,
+		"defref_ifrs-full_GrossProfit":                                        finDataGrossMargin,
+		"GrossProfit":                                                         finDataGrossMargin,
+		"defref_ifrs-full_OperatingExpense":                                   finDataOpsExpense,
+		"OperatingExpense":                                                    finDataOpsExpense,
+		"defref_ifrs-full_ProfitLossFromOperatingActivities":                  finDataOpsIncome,
+		"ProfitLossFromOperatingActivities":                                   finDataOpsIncome,
+		"defref_ifrs-full_ProfitLoss":                                         finDataNetIncome,
+		"ProfitLoss":                                                          finDataNetIncome,
+		"defref_ifrs-full_ProfitLossAttributableToOwnersOfParent":             finDataNetIncome,
+		"ProfitLossAttributableToOwnersOfParent":                              finDataNetIncome,
+		"defref_ifrs-full_AdjustedWeightedAverageShares":                      finDataWAShares,
+		"AdjustedWeightedAverageShares":                                       finDataWAShares,
+		"defref_ifrs-full_WeightedAverageShares":                              finDataWAShares,
+		"WeightedAverageShares":                                               finDataWAShares,
+		"defref_ifrs-full_DividendsRecognisedAsDistributionsToOwnersPerShare": finDataDps,
+		"DividendsRecognisedAsDistributionsToOwnersPerShare":                  finDataDps,
+
+		//Cash Flow Sheet info
+		"defref_ifrs-full_CashFlowsFromUsedInOperatingActivities":                                                                         finDataOpCashFlow,
+		"CashFlowsFromUsedInOperatingActivities":                                                                                          finDataOpCashFlow,
+		"defref_ifrs-full_PurchaseOfPropertyPlantAndEquipmentClassifiedAsInvestingActivities":                                             finDataCapEx,
+		"PurchaseOfPropertyPlantAndEquipmentClassifiedAsInvestingActivities":                                                              finDataCapEx,
+		"defref_ifrs-full_PurchaseOfPropertyPlantAndEquipmentIntangibleAssetsOtherThanGoodwillInvestmentPropertyAndOtherNoncurrentAssets": finDataCapEx,
+		"PurchaseOfPropertyPlantAndEquipmentIntangibleAssetsOtherThanGoodwillInvestmentPropertyAndOtherNoncurrentAssets":                  finDataCapEx,
+		"defref_ifrs-full_DividendsPaidClassifiedAsFinancingActivities":                                                                   finDataDividend,
+		"DividendsPaidClassifiedAsFinancingActivities":                                                                                    finDataDividend,
+		"defref_ifrs-full_DividendsPaid":                                                                                                  finDataDividend,
+		"DividendsPaid":                                                                                                                   finDataDividend,
+		"defref_ifrs-full_InterestPaidClassifiedAsOperatingActivities":                                                                    finDataInterest,
+		"InterestPaidClassifiedAsOperatingActivities":                                                                                     finDataInterest,
+		"defref_ifrs-full_InterestPaidClassifiedAsFinancingActivities":                                                                    finDataInterest,
+		"InterestPaidClassifiedAsFinancingActivities":                                                                                     finDataInterest,
+
+		//Entity sheet information
+		"defref_dei_EntityCommonStockSharesOutstanding": finDataSharesOutstanding,
+		"EntityCommonStockSharesOutstanding":            finDataSharesOutstanding,
+	}
 )
 
-func getFinDataTypeFromXBRLTag(key string) finDataType {
+// detectTaxonomy finds the taxonomy used by the tags of a report. A filing
+// is made using either the US-GAAP or the IFRS taxonomy.
+func detectTaxonomy(keys []string) taxonomy {
+	gaap, ifrs := 0, 0
+	for _, key := range keys {
+		if strings.HasPrefix(key, "defref_"+string(taxonomyIFRS)+"_") {
+			ifrs++
+		} else if strings.HasPrefix(key, "defref_"+string(taxonomyGAAP)+"_") {
+			gaap++
+		}
+	}
+	if ifrs > gaap {
+		return taxonomyIFRS
+	}
+	return taxonomyGAAP
+}
+
+func getFinDataTypeFromXBRLTag(key string, tax taxonomy) finDataType {
 
-	data, ok := xbrlTags[key]
+	tags := xbrlTags
+	if tax == taxonomyIFRS {
+		tags = ifrsTags
+	}
+	data, ok := tags[key]
 	if !ok {
 
 		// Now look for non-gaap filing
@@ -126,7 +330,7 @@ func getFinDataTypeFromXBRLTag(key string) finDataType {
 		// as defref_msft_XXX
 		splits := strings.Split(key, "_")
 		if len(splits) == 3 {
-			data, ok = xbrlTags[splits[2]]
+			data, ok = tags[splits[2]]
 			if ok {
 				return data
 			}